@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -10,7 +11,7 @@ import (
 // each command gets its own set of args,
 // defines its own entry point, and provides its own help
 type Command struct {
-	Run  func(cmd *Command, args ...string)
+	Run  func(ctx context.Context, cmd *Command, args ...string)
 	Flag flag.FlagSet
 
 	Name  string
@@ -20,11 +21,11 @@ type Command struct {
 	Help    string
 }
 
-func (c *Command) Exec(args []string) {
+func (c *Command) Exec(ctx context.Context, args []string) {
 	c.Flag.Usage = func() {
 		fmt.Fprintln(os.Stderr, c.Usage)
 		c.Flag.PrintDefaults()
 	}
 	c.Flag.Parse(args)
-	c.Run(c, c.Flag.Args()...)
+	c.Run(ctx, c, c.Flag.Args()...)
 }
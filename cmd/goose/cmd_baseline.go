@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var baselineCmd = &Command{
+	Name:    "baseline",
+	Usage:   "baseline [-force] <version>",
+	Summary: "Record migrations up to a version as applied without running them",
+	Help: `Adopt a database whose schema already exists, such as one restored from a
+snapshot, by recording every migration up to and including the given version
+as applied. Nothing is run, and the records are flagged as baselined.
+
+baseline refuses if the version table already records migrations. Pass
+-force to record the remaining migrations up to the version anyway.`,
+	Run:  baselineRun,
+	Flag: *flag.NewFlagSet("baseline", flag.ExitOnError),
+}
+
+var baselineForce bool
+
+func init() {
+	baselineCmd.Flag.BoolVar(&baselineForce, "force", false, "baseline even if the version table already has history")
+}
+
+func baselineRun(ctx context.Context, cmd *Command, args ...string) {
+	if len(args) != 1 {
+		log.Fatal("goose baseline: version required")
+	}
+
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	version, err := parseTargetVersion(conf, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if version == 0 {
+		log.Fatal("goose baseline: version must be greater than 0")
+	}
+
+	db, err := goosedb.OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := goosedb.BaselineContext(ctx, conf, conf.MigrationsDir, version, db, baselineForce); err != nil {
+		log.Fatal(err)
+	}
+}
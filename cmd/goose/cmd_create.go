@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/user"
 	"path/filepath"
 	"time"
 
@@ -13,14 +15,33 @@ import (
 
 var createCmd = &Command{
 	Name:    "create",
-	Usage:   "create <migration-name>",
+	Usage:   "create [-seq] [-template <name>] <migration-name>",
 	Summary: "Create the scaffolding for a new migration",
-	Help:    `Create a file with a new migration. The file will have the given name`,
-	Run:     createRun,
-	Flag:    *flag.NewFlagSet("create", flag.ExitOnError),
+	Help: `Create a file with a new migration. The file will have the given name,
+prefixed with the current UTC time as its version.
+
+With -seq, the version is instead the number after the highest version in
+the migrations directory, zero-padded like the existing files. Timestamped
+versions count too, so the new migration sorts after every existing one.
+
+The file is rendered from the Go text/template called <name>.sql in the
+templates directory (db/templates, or templatesdir in dbconf.yml), chosen
+with -template, the template key in dbconf.yml, or else default.sql if it
+exists. Templates can use {{.Name}}, {{.Version}}, {{.Author}} and {{.Env}}.
+Without a template, a file with empty Up and Down sections is created.`,
+	Run:  createRun,
+	Flag: *flag.NewFlagSet("create", flag.ExitOnError),
 }
 
-func createRun(cmd *Command, args ...string) {
+var createSeq bool
+var createTemplate string
+
+func init() {
+	createCmd.Flag.BoolVar(&createSeq, "seq", false, "number the migration sequentially instead of with a timestamp")
+	createCmd.Flag.StringVar(&createTemplate, "template", "", "name of the template in the templates directory, or path to a .sql template file")
+}
+
+func createRun(ctx context.Context, cmd *Command, args ...string) {
 	if len(args) < 1 {
 		log.Fatal("goose create: migration name required")
 	}
@@ -36,7 +57,22 @@ func createRun(cmd *Command, args ...string) {
 		log.Fatal(err)
 	}
 
-	n, err := goose.CreateMigration(args[0], migrationType, conf.MigrationsDir, time.Now().UTC())
+	name := createTemplate
+	if name == "" {
+		name = conf.Template
+	}
+	tmpl, err := goose.LoadTemplate(conf.TemplatesDir, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	n, err := goose.CreateMigrationWithOptions(args[0], migrationType, conf.MigrationsDir, goose.CreateOptions{
+		Time:       time.Now().UTC(),
+		Sequential: createSeq,
+		Template:   tmpl,
+		Author:     currentAuthor(),
+		Env:        conf.Env,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -48,3 +84,11 @@ func createRun(cmd *Command, args ...string) {
 
 	fmt.Println("goose: created", a)
 }
+
+// currentAuthor returns the name of the user running goose, for templates.
+func currentAuthor() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
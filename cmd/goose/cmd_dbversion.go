@@ -1,30 +1,45 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"flag"
 	"log"
+	"os"
 
 	"github.com/kevinburke/goose/lib/goosedb"
 )
 
 var dbVersionCmd = &Command{
 	Name:    "dbversion",
-	Usage:   "",
+	Usage:   "dbversion [-format=text|json|tsv]",
 	Summary: "Print the current version of the database",
 	Help:    `dbversion extended help here...`,
 	Run:     dbVersionRun,
+	Flag:    *flag.NewFlagSet("dbversion", flag.ExitOnError),
 }
 
-func dbVersionRun(cmd *Command, args ...string) {
+var dbVersionFormat string
+
+func init() {
+	dbVersionCmd.Flag.StringVar(&dbVersionFormat, "format", formatText, formatUsage)
+}
+
+func dbVersionRun(ctx context.Context, cmd *Command, args ...string) {
+	if err := checkFormat(dbVersionFormat); err != nil {
+		log.Fatal(err)
+	}
+
 	conf, err := dbConfFromFlags()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	current, err := goosedb.GetDBVersion(conf)
+	current, err := goosedb.GetDBVersionContext(ctx, conf)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	fmt.Printf("goose: dbversion %v\n", current)
+	if err := writeDBVersion(os.Stdout, dbVersionFormat, conf.Env, current); err != nil {
+		log.Fatal(err)
+	}
 }
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
 
-	"github.com/kevinburke/goose/lib/goose"
 	"github.com/kevinburke/goose/lib/goosedb"
 )
 
@@ -17,23 +18,41 @@ var downCmd = &Command{
 	Run:     downRun,
 }
 
-func downRun(_ *Command, args ...string) {
+var (
+	downDryRun bool
+	downFormat string
+)
+
+func init() {
+	downCmd.Flag.BoolVar(&downDryRun, "dry-run", false, dryRunUsage)
+	downCmd.Flag.StringVar(&downFormat, "format", formatText, formatUsage)
+}
+
+func downRun(ctx context.Context, _ *Command, args ...string) {
+	if err := checkFormat(downFormat); err != nil {
+		log.Fatal(err)
+	}
+
 	conf, err := dbConfFromFlags()
 	if err != nil {
 		log.Fatal(err)
 	}
+	conf.Logger = newRunLogger(os.Stdout, downFormat)
+	if downDryRun {
+		conf.DryRun = os.Stdout
+	}
 
-	current, err := goosedb.GetDBVersion(conf)
+	current, err := goosedb.GetDBVersionContext(ctx, conf)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	previous, err := goose.GetPreviousDBVersion(conf.MigrationsDir, current)
+	previous, err := previousVersion(conf, current)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err = goosedb.RunMigrations(conf, conf.MigrationsDir, previous); err != nil {
+	if err = goosedb.RunMigrationsContext(ctx, conf, conf.MigrationsDir, previous); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var downToCmd = &Command{
+	Name:    "down-to",
+	Usage:   "down-to <version>",
+	Summary: "Roll back the DB to a specific version",
+	Help:    `Roll back every applied migration newer than the given version. Use 0 to roll back everything`,
+	Run:     downToRun,
+	Flag:    *flag.NewFlagSet("down-to", flag.ExitOnError),
+}
+
+var downToDryRun bool
+
+func init() {
+	downToCmd.Flag.BoolVar(&downToDryRun, "dry-run", false, dryRunUsage)
+}
+
+func downToRun(ctx context.Context, cmd *Command, args ...string) {
+	if len(args) != 1 {
+		log.Fatal("goose down-to: version required")
+	}
+
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if downToDryRun {
+		conf.DryRun = os.Stdout
+	}
+
+	target, err := parseTargetVersion(conf, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := goosedb.OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	current, err := goosedb.EnsureDBVersionContext(ctx, conf, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if target > current {
+		log.Fatalf("goose down-to: version %d is newer than the current version %d; use up-to", target, current)
+	}
+
+	if err := migrateTo(ctx, conf, db, target); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var fanOutCmd = &Command{
+	Name:    "fan-out",
+	Usage:   "fan-out [-to <version>] [-concurrency <n>] [-continue-on-error]",
+	Summary: "Migrate every database or schema listed under fanout in dbconf.yml",
+	Help: `Migrate each target in the fanout section of dbconf.yml to the most
+recent version, or to the version given with -to, and print a summary of
+each target's starting and ending version and any error.
+
+Targets are Postgres schemas in the configured database, listed under
+schemas or returned by schemaquery, and databases listed one connection
+string per line in dsnfile. -concurrency and -continue-on-error override
+the concurrency and continueonerror keys. goose exits non-zero if any
+target failed.`,
+	Run:  fanOutRun,
+	Flag: *flag.NewFlagSet("fan-out", flag.ExitOnError),
+}
+
+var (
+	fanOutTo              string
+	fanOutConcurrency     int
+	fanOutContinueOnError bool
+	fanOutDryRun          bool
+	fanOutFormat          string
+)
+
+func init() {
+	fanOutCmd.Flag.StringVar(&fanOutTo, "to", "", "version to migrate every target to (default = the most recent)")
+	fanOutCmd.Flag.IntVar(&fanOutConcurrency, "concurrency", 0, "how many targets to migrate at once (default = concurrency in dbconf.yml, or 1)")
+	fanOutCmd.Flag.BoolVar(&fanOutContinueOnError, "continue-on-error", false, "keep migrating the other targets after one fails")
+	fanOutCmd.Flag.BoolVar(&fanOutDryRun, "dry-run", false, dryRunUsage)
+	fanOutCmd.Flag.StringVar(&fanOutFormat, "format", formatText, formatUsage)
+}
+
+func fanOutRun(ctx context.Context, cmd *Command, args ...string) {
+	if err := checkFormat(fanOutFormat); err != nil {
+		log.Fatal(err)
+	}
+
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if fanOutConcurrency > 0 {
+		conf.FanOut.Concurrency = fanOutConcurrency
+	}
+	if fanOutContinueOnError {
+		conf.FanOut.ContinueOnError = true
+	}
+	if fanOutDryRun {
+		// one target at a time, so the SQL of each stays together
+		conf.DryRun = os.Stdout
+		conf.FanOut.Concurrency = 1
+	}
+	if fanOutFormat != formatText {
+		// keep stdout for the summary
+		conf.Logger = newRunLogger(os.Stderr, fanOutFormat)
+	}
+
+	var version int64
+	if fanOutTo == "" {
+		version, err = latestVersion(conf)
+	} else {
+		version, err = parseTargetVersion(conf, fanOutTo)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	targets, err := goosedb.TargetsContext(ctx, conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results, err := goosedb.MigrateTargetsContext(ctx, conf, targets, version)
+	if werr := writeTargetResults(os.Stdout, fanOutFormat, results); werr != nil {
+		log.Fatal(werr)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/kevinburke/goose/lib/goose"
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var fixCmd = &Command{
+	Name:    "fix",
+	Usage:   "fix [-dry-run]",
+	Summary: "Renumber timestamped migrations sequentially",
+	Help: `Rename every timestamped migration file that hasn't been applied to the
+database, such as one made by "goose create" on a branch, to the sequential
+versions after the highest remaining sequential version in the migrations
+directory. Unapplied files after the first one renamed are renumbered with
+it, so files keep their relative order and the rest of their name.
+
+Applied migrations are never renamed, since their recorded versions must
+keep matching the files. If a timestamped migration has been applied, the
+new versions would sort before it, so fix refuses to rename anything. Pass
+-dry-run to print the renames without making them.`,
+	Run:  fixRun,
+	Flag: *flag.NewFlagSet("fix", flag.ExitOnError),
+}
+
+var fixDryRun bool
+
+func init() {
+	fixCmd.Flag.BoolVar(&fixDryRun, "dry-run", false, "print the renames without making them")
+}
+
+func fixRun(ctx context.Context, cmd *Command, args ...string) {
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := goosedb.OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	statuses, err := goosedb.GetMigrationStatusContext(ctx, conf, conf.MigrationsDir, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	applied := make(map[int64]bool)
+	for _, st := range statuses {
+		if st.Applied {
+			applied[st.Version] = true
+		}
+	}
+
+	fix, verb := goose.FixMigrations, "renamed"
+	if fixDryRun {
+		fix, verb = goose.PlanFixMigrations, "would rename"
+	}
+	renames, err := fix(conf.MigrationsDir, applied)
+	for _, r := range renames {
+		fmt.Printf("goose: %s %s -> %s\n", verb, filepath.Base(r.Source), filepath.Base(r.NewSource))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(renames) == 0 {
+		fmt.Println("goose: no unapplied timestamped migrations to renumber")
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var historyCmd = &Command{
+	Name:    "history",
+	Usage:   "history [-from <version>] [-to <version>] [-since <date>] [-until <date>] [-format=text|json|tsv]",
+	Summary: "Print every migration applied or rolled back, oldest first",
+	Help: `Print each row of the version table: every time a migration was
+applied, rolled back or baselined, with when, by whom, on which host and
+how long it took.
+
+-from and -to limit the versions shown, inclusive. -since and -until limit
+when the migrations ran, inclusive, and take an RFC 3339 time such as
+2024-03-01T12:00:00Z or a date such as 2024-03-01, which -until reads as
+the end of that day. Dates without a time zone are in UTC.`,
+	Run:  historyRun,
+	Flag: *flag.NewFlagSet("history", flag.ExitOnError),
+}
+
+var (
+	historyFrom   string
+	historyTo     string
+	historySince  string
+	historyUntil  string
+	historyFormat string
+)
+
+func init() {
+	historyCmd.Flag.StringVar(&historyFrom, "from", "", "only show versions from this one on")
+	historyCmd.Flag.StringVar(&historyTo, "to", "", "only show versions up to this one")
+	historyCmd.Flag.StringVar(&historySince, "since", "", "only show migrations run at or after this time or date")
+	historyCmd.Flag.StringVar(&historyUntil, "until", "", "only show migrations run at or before this time or date")
+	historyCmd.Flag.StringVar(&historyFormat, "format", formatText, formatUsage)
+}
+
+func historyRun(ctx context.Context, cmd *Command, args ...string) {
+	if err := checkFormat(historyFormat); err != nil {
+		log.Fatal(err)
+	}
+	filter, err := historyFilter()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := goosedb.OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		log.Fatal("couldn't open DB:", err)
+	}
+	defer db.Close()
+
+	entries, err := goosedb.GetHistoryContext(ctx, conf, db, filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writeHistory(os.Stdout, historyFormat, conf.Env, entries); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func historyFilter() (goosedb.HistoryFilter, error) {
+	var f goosedb.HistoryFilter
+	var err error
+	if historyFrom != "" {
+		if f.FromVersion, err = strconv.ParseInt(historyFrom, 10, 64); err != nil || f.FromVersion < 0 {
+			return f, fmt.Errorf("goose: invalid version %q", historyFrom)
+		}
+	}
+	if historyTo != "" {
+		if f.ToVersion, err = strconv.ParseInt(historyTo, 10, 64); err != nil || f.ToVersion < 0 {
+			return f, fmt.Errorf("goose: invalid version %q", historyTo)
+		}
+	}
+	if historySince != "" {
+		if f.Since, err = parseHistoryTime(historySince, false); err != nil {
+			return f, err
+		}
+	}
+	if historyUntil != "" {
+		if f.Until, err = parseHistoryTime(historyUntil, true); err != nil {
+			return f, err
+		}
+	}
+	return f, nil
+}
+
+// parseHistoryTime parses an RFC 3339 time or a date. A date is the start of
+// that day in UTC, or its last instant if endOfDay is set.
+func parseHistoryTime(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("goose: invalid time %q, want a date such as 2024-03-01 or an RFC 3339 time", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
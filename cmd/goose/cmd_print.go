@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -15,7 +16,7 @@ var printCmd = &Command{
 	Flag:    *flag.NewFlagSet("print", flag.ExitOnError),
 }
 
-func printRun(cmd *Command, args ...string) {
+func printRun(ctx context.Context, cmd *Command, args ...string) {
 	conf, err := dbConfFromFlags()
 	if err != nil {
 		log.Fatal(err)
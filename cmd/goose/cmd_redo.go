@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"os"
 
 	"github.com/kevinburke/goose/lib/goose"
 	"github.com/kevinburke/goose/lib/goosedb"
@@ -13,29 +16,57 @@ var redoCmd = &Command{
 	Summary: "Re-run the latest migration",
 	Help:    `redo extended help here...`,
 	Run:     redoRun,
+	Flag:    *flag.NewFlagSet("redo", flag.ExitOnError),
 }
 
-func redoRun(cmd *Command, args ...string) {
+var redoDryRun bool
+
+func init() {
+	redoCmd.Flag.BoolVar(&redoDryRun, "dry-run", false, dryRunUsage)
+}
+
+func redoRun(ctx context.Context, cmd *Command, args ...string) {
 	conf, err := dbConfFromFlags()
 	if err != nil {
 		log.Fatal(err)
 	}
+	if redoDryRun {
+		conf.DryRun = os.Stdout
+	}
 
-	current, err := goosedb.GetDBVersion(conf)
+	current, err := goosedb.GetDBVersionContext(ctx, conf)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	previous, err := goose.GetPreviousDBVersion(conf.MigrationsDir, current)
+	previous, err := previousVersion(conf, current)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := goosedb.RunMigrations(conf, conf.MigrationsDir, previous); err != nil {
+	if err := goosedb.RunMigrationsContext(ctx, conf, conf.MigrationsDir, previous); err != nil {
 		log.Fatal(err)
 	}
 
-	if err := goosedb.RunMigrations(conf, conf.MigrationsDir, current); err != nil {
+	if redoDryRun {
+		// nothing was rolled back, so a second run would find nothing to
+		// do; print the latest migration's up section directly
+		ms, err := goose.CollectTrackMigrations(conf.Track, conf.MigrationsDir, previous, current)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, m := range ms {
+			if m.Version != current {
+				continue
+			}
+			if err := goosedb.WriteMigrationSQL(os.Stdout, conf, m, true); err != nil {
+				log.Fatal(err)
+			}
+		}
+		return
+	}
+
+	if err := goosedb.RunMigrationsContext(ctx, conf, conf.MigrationsDir, current); err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var repairCmd = &Command{
+	Name:    "repair",
+	Usage:   "repair",
+	Summary: "Accept the current checksums of applied migrations",
+	Help: `Record the current checksum and filename of every applied migration,
+so that verify and up -verify accept files that were edited after they were
+applied. Nothing is re-run.`,
+	Run:  repairRun,
+	Flag: *flag.NewFlagSet("repair", flag.ExitOnError),
+}
+
+func repairRun(ctx context.Context, cmd *Command, args ...string) {
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := goosedb.OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	n, err := goosedb.RepairChecksumsContext(ctx, conf, conf.MigrationsDir, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("goose: updated checksums for %d migration(s)\n", n)
+}
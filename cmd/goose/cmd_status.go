@@ -1,74 +1,51 @@
 package main
 
 import (
-	"database/sql"
-	"fmt"
+	"context"
+	"flag"
 	"log"
-	"path/filepath"
-	"time"
+	"os"
 
-	"github.com/kevinburke/goose/lib/goose"
 	"github.com/kevinburke/goose/lib/goosedb"
 )
 
 var statusCmd = &Command{
 	Name:    "status",
-	Usage:   "",
+	Usage:   "status [-format=text|json|tsv]",
 	Summary: "dump the migration status for the current DB",
 	Help:    `status extended help here...`,
 	Run:     statusRun,
+	Flag:    *flag.NewFlagSet("status", flag.ExitOnError),
 }
 
-func statusRun(cmd *Command, args ...string) {
+var statusFormat string
 
-	conf, err := dbConfFromFlags()
-	if err != nil {
+func init() {
+	statusCmd.Flag.StringVar(&statusFormat, "format", formatText, formatUsage)
+}
+
+func statusRun(ctx context.Context, cmd *Command, args ...string) {
+	if err := checkFormat(statusFormat); err != nil {
 		log.Fatal(err)
 	}
 
-	// collect all migrations
-	min := int64(0)
-	max := int64((1 << 63) - 1)
-	migrations, e := goose.CollectMigrations(conf.MigrationsDir, min, max)
-	if e != nil {
-		log.Fatal(e)
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	db, e := goosedb.OpenDBFromDBConf(conf)
+	db, e := goosedb.OpenDBFromDBConfContext(ctx, conf)
 	if e != nil {
 		log.Fatal("couldn't open DB:", e)
 	}
 	defer db.Close()
 
-	// must ensure that the version table exists if we're running on a pristine DB
-	if _, e := goosedb.EnsureDBVersion(conf, db); e != nil {
-		log.Fatal(e)
-	}
-
-	fmt.Printf("goose: status for environment '%v'\n", conf.Env)
-	fmt.Println("    Applied At                  Migration")
-	fmt.Println("    =======================================")
-	for _, m := range migrations {
-		printMigrationStatus(db, m.Version, filepath.Base(m.Source))
-	}
-}
-
-func printMigrationStatus(db *sql.DB, version int64, script string) {
-	var row goose.MigrationRecord
-	q := fmt.Sprintf("SELECT tstamp, is_applied FROM goose_db_version WHERE version_id=%d ORDER BY tstamp DESC LIMIT 1", version)
-	e := db.QueryRow(q).Scan(&row.TStamp, &row.IsApplied)
-
-	if e != nil && e != sql.ErrNoRows {
-		log.Fatal(e)
+	statuses, err := goosedb.GetMigrationStatusContext(ctx, conf, conf.MigrationsDir, db)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	var appliedAt string
-
-	if row.IsApplied {
-		appliedAt = row.TStamp.Format(time.ANSIC)
-	} else {
-		appliedAt = "Pending"
+	if err := writeStatus(os.Stdout, statusFormat, conf.Env, statuses); err != nil {
+		log.Fatal(err)
 	}
-
-	fmt.Printf("    %-24s -- %v\n", appliedAt, script)
 }
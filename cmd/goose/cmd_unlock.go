@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var unlockCmd = &Command{
+	Name:    "unlock",
+	Usage:   "unlock",
+	Summary: "Release a migration lock left by a killed run",
+	Help: `Release the migration lock taken with -lock, whoever holds it. Only
+the sqlite3 lock, a row in the <versiontable>_lock table, can outlive a run
+that was killed while holding it; the Postgres and MySQL locks are released
+when the connection holding them closes.
+
+Make sure no other run is migrating the database first.`,
+	Run:  unlockRun,
+	Flag: *flag.NewFlagSet("unlock", flag.ExitOnError),
+}
+
+func unlockRun(ctx context.Context, cmd *Command, args ...string) {
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := goosedb.OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	cleared, err := goosedb.ClearLockContext(ctx, conf, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cleared {
+		fmt.Println("goose: released the migration lock")
+	} else {
+		fmt.Println("goose: the migration lock was not held")
+	}
+}
@@ -1,10 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"os"
 
-	"github.com/kevinburke/goose/lib/goose"
 	"github.com/kevinburke/goose/lib/goosedb"
 )
 
@@ -12,24 +13,51 @@ var upCmd = &Command{
 	Name:    "up",
 	Usage:   "",
 	Summary: "Migrate the DB to the most recent version available",
-	Help:    `up extended help here...`,
-	Run:     upRun,
-	Flag:    *flag.NewFlagSet("up", flag.ExitOnError),
+	Help: `Apply every pending migration up to the most recent version.
+
+By default, up fails if it finds migrations older than the current version
+that were never applied (for example, from a branch merged after newer
+migrations ran). Pass -allow-missing to apply them out of order instead.`,
+	Run:  upRun,
+	Flag: *flag.NewFlagSet("up", flag.ExitOnError),
+}
+
+var (
+	upAllowMissing bool
+	upVerify       bool
+	upDryRun       bool
+	upFormat       string
+)
+
+func init() {
+	upCmd.Flag.BoolVar(&upAllowMissing, "allow-missing", false, "apply unapplied migrations older than the current version")
+	upCmd.Flag.BoolVar(&upVerify, "verify", false, "fail if an applied migration's file has changed or disappeared")
+	upCmd.Flag.BoolVar(&upDryRun, "dry-run", false, dryRunUsage)
+	upCmd.Flag.StringVar(&upFormat, "format", formatText, formatUsage)
 }
 
-func upRun(cmd *Command, args ...string) {
+func upRun(ctx context.Context, cmd *Command, args ...string) {
+	if err := checkFormat(upFormat); err != nil {
+		log.Fatal(err)
+	}
 
 	conf, err := dbConfFromFlags()
 	if err != nil {
 		log.Fatal(err)
 	}
+	conf.AllowMissing = upAllowMissing
+	conf.VerifyChecksums = upVerify
+	conf.Logger = newRunLogger(os.Stdout, upFormat)
+	if upDryRun {
+		conf.DryRun = os.Stdout
+	}
 
-	target, err := goose.GetMostRecentDBVersion(conf.MigrationsDir)
+	target, err := latestVersion(conf)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err := goosedb.RunMigrations(conf, conf.MigrationsDir, target); err != nil {
+	if err := goosedb.RunMigrationsContext(ctx, conf, conf.MigrationsDir, target); err != nil {
 		log.Fatal(err)
 	}
 }
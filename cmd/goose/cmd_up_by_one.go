@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var upByOneCmd = &Command{
+	Name:    "up-by-one",
+	Usage:   "up-by-one",
+	Summary: "Migrate the DB up by one version",
+	Help:    `Apply the next pending migration`,
+	Run:     upByOneRun,
+	Flag:    *flag.NewFlagSet("up-by-one", flag.ExitOnError),
+}
+
+func upByOneRun(ctx context.Context, cmd *Command, args ...string) {
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	latest, err := latestVersion(conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := goosedb.OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	plan, err := goosedb.PlanMigrationsOnDbContext(ctx, conf, conf.MigrationsDir, latest, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(plan) == 0 {
+		fmt.Println("goose: no migrations to run")
+		return
+	}
+
+	if err := migrateTo(ctx, conf, db, plan[0].Version); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var upToCmd = &Command{
+	Name:    "up-to",
+	Usage:   "up-to <version>",
+	Summary: "Migrate the DB up to a specific version",
+	Help:    `Apply every pending migration up to and including the given version`,
+	Run:     upToRun,
+	Flag:    *flag.NewFlagSet("up-to", flag.ExitOnError),
+}
+
+var upToDryRun bool
+
+func init() {
+	upToCmd.Flag.BoolVar(&upToDryRun, "dry-run", false, dryRunUsage)
+}
+
+func upToRun(ctx context.Context, cmd *Command, args ...string) {
+	if len(args) != 1 {
+		log.Fatal("goose up-to: version required")
+	}
+
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if upToDryRun {
+		conf.DryRun = os.Stdout
+	}
+
+	target, err := parseTargetVersion(conf, args[0])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := goosedb.OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	current, err := goosedb.EnsureDBVersionContext(ctx, conf, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if target < current {
+		log.Fatalf("goose up-to: version %d is older than the current version %d; use down-to", target, current)
+	}
+
+	if err := migrateTo(ctx, conf, db, target); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var validateCmd = &Command{
+	Name:    "validate",
+	Usage:   "validate",
+	Summary: "Check migrations for problems without connecting to a database",
+	Help: `Check every migration in the migrations directory for invalid names,
+duplicate versions, unparseable or empty sections and statements that cannot
+run in a transaction alongside others. Exits non-zero if there are problems.
+
+With -track, the track's migrations directory from dbconf.yml is checked
+instead.`,
+	Run:  validateRun,
+	Flag: *flag.NewFlagSet("validate", flag.ExitOnError),
+}
+
+func validateRun(ctx context.Context, cmd *Command, args ...string) {
+	// validate doesn't need a database, so don't require dbconf.yml to
+	// describe one; CI may not have the credentials. Tracks are only
+	// configured there, though.
+	dir := filepath.Join(*flagPath, "migrations")
+	if *flagTrack != "" {
+		conf, err := dbConfFromFlags()
+		if err != nil {
+			log.Fatal(err)
+		}
+		dir = conf.MigrationsDir
+	}
+
+	problems, err := goosedb.ValidateMigrations(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "goose: found %d problem(s) in %s\n", len(problems), dir)
+		os.Exit(1)
+	}
+	fmt.Printf("goose: no problems found in %s\n", dir)
+}
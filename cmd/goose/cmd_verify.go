@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var verifyCmd = &Command{
+	Name:    "verify",
+	Usage:   "verify",
+	Summary: "Check that applied migrations match their files",
+	Help: `Compare the checksum recorded for every applied migration with its file.
+Exits non-zero if a file has changed or disappeared since it was applied.
+Use repair to accept the current files.`,
+	Run:  verifyRun,
+	Flag: *flag.NewFlagSet("verify", flag.ExitOnError),
+}
+
+func verifyRun(ctx context.Context, cmd *Command, args ...string) {
+	conf, err := dbConfFromFlags()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := goosedb.OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	problems, err := goosedb.VerifyChecksumsContext(ctx, conf, conf.MigrationsDir, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "goose: %d applied migration(s) do not match their files\n", len(problems))
+		os.Exit(1)
+	}
+	fmt.Println("goose: all applied migrations match their files")
+}
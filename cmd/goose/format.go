@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+// Output formats accepted by the -format flag.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatTSV  = "tsv"
+)
+
+const formatUsage = "output format: text, json or tsv"
+
+func checkFormat(format string) error {
+	switch format {
+	case formatText, formatJSON, formatTSV:
+		return nil
+	}
+	return fmt.Errorf("goose: unknown format %q, want text, json or tsv", format)
+}
+
+// migrationResult is the machine-readable record for a migration that ran.
+// The field names are part of goose's output contract; don't change them.
+type migrationResult struct {
+	Version    int64   `json:"version"`
+	Filename   string  `json:"filename"`
+	Direction  string  `json:"direction"`
+	Outcome    string  `json:"outcome"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// resultHandler is a slog.Handler that turns the per-migration records logged
+// by goosedb into JSON lines or TSV rows. Other records are dropped.
+type resultHandler struct {
+	mu          sync.Mutex
+	w           io.Writer
+	format      string
+	wroteHeader bool
+}
+
+// newRunLogger returns the logger to use for a migration run in format, or
+// nil to use goosedb's default text output.
+func newRunLogger(w io.Writer, format string) *slog.Logger {
+	if format == formatText {
+		return nil
+	}
+	return slog.New(&resultHandler{w: w, format: format})
+}
+
+func (h *resultHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *resultHandler) Handle(_ context.Context, r slog.Record) error {
+	var res migrationResult
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "version":
+			res.Version = a.Value.Int64()
+		case "file":
+			res.Filename = a.Value.String()
+		case "direction":
+			res.Direction = a.Value.String()
+		case "outcome":
+			res.Outcome = a.Value.String()
+		case "duration":
+			res.DurationMS = float64(a.Value.Duration()) / float64(time.Millisecond)
+		case "error":
+			res.Error = a.Value.String()
+		}
+		return true
+	})
+	if res.Outcome == "" {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.format == formatJSON {
+		return json.NewEncoder(h.w).Encode(res)
+	}
+	if !h.wroteHeader {
+		h.wroteHeader = true
+		if _, err := io.WriteString(h.w, "version\tfilename\tdirection\toutcome\tduration_ms\terror\n"); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(h.w, "%d\t%s\t%s\t%s\t%.3f\t%s\n",
+		res.Version, res.Filename, res.Direction, res.Outcome, res.DurationMS, tsvEscape(res.Error))
+	return err
+}
+
+func (h *resultHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *resultHandler) WithGroup(string) slog.Handler      { return h }
+
+// tsvEscape replaces the characters that would break a TSV row.
+var tsvEscape = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace
+
+// migrationStatus is the machine-readable record for one migration in
+// `goose status`. AppliedAt is null for pending migrations.
+type migrationStatus struct {
+	Version   int64      `json:"version"`
+	Filename  string     `json:"filename"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at"`
+	Baselined bool       `json:"baselined"`
+
+	// how the migration was applied, if recorded
+	DurationMS   *int64 `json:"duration_ms,omitempty"`
+	AppliedBy    string `json:"applied_by,omitempty"`
+	Hostname     string `json:"hostname,omitempty"`
+	GooseVersion string `json:"goose_version,omitempty"`
+}
+
+// statusRecords returns the machine-readable record of each status.
+func statusRecords(statuses []goosedb.MigrationStatus) []migrationStatus {
+	records := make([]migrationStatus, 0, len(statuses))
+	for _, st := range statuses {
+		ms := migrationStatus{Version: st.Version, Filename: filepath.Base(st.Source), Applied: st.Applied, Baselined: st.Baselined,
+			AppliedBy: st.AppliedBy, Hostname: st.Hostname, GooseVersion: st.GooseVersion}
+		if st.Applied {
+			ms.AppliedAt = &st.AppliedAt
+		}
+		if st.Duration > 0 {
+			d := st.Duration.Milliseconds()
+			ms.DurationMS = &d
+		}
+		records = append(records, ms)
+	}
+	return records
+}
+
+// statusTSVHeader names the TSV status columns, one for each field of
+// migrationStatus, in the same order and with the same names as the JSON.
+const statusTSVHeader = "version\tfilename\tapplied\tapplied_at\tbaselined\tduration_ms\tapplied_by\thostname\tgoose_version\n"
+
+func writeStatus(w io.Writer, format, env string, statuses []goosedb.MigrationStatus) error {
+	switch format {
+	case formatJSON:
+		out := struct {
+			Env        string            `json:"env"`
+			Migrations []migrationStatus `json:"migrations"`
+		}{Env: env, Migrations: statusRecords(statuses)}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+
+	case formatTSV:
+		if _, err := io.WriteString(w, statusTSVHeader); err != nil {
+			return err
+		}
+		for _, ms := range statusRecords(statuses) {
+			// null fields are empty
+			appliedAt, duration := "", ""
+			if ms.AppliedAt != nil {
+				appliedAt = ms.AppliedAt.Format(time.RFC3339)
+			}
+			if ms.DurationMS != nil {
+				duration = fmt.Sprint(*ms.DurationMS)
+			}
+			if _, err := fmt.Fprintf(w, "%d\t%s\t%t\t%s\t%t\t%s\t%s\t%s\t%s\n", ms.Version, ms.Filename, ms.Applied, appliedAt,
+				ms.Baselined, duration, tsvEscape(ms.AppliedBy), tsvEscape(ms.Hostname), tsvEscape(ms.GooseVersion)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fmt.Fprintf(w, "goose: status for environment '%v'\n", env)
+	fmt.Fprintln(w, "    Applied At                  Migration")
+	fmt.Fprintln(w, "    =======================================")
+	for _, st := range statuses {
+		appliedAt := "Pending"
+		if st.Applied {
+			appliedAt = st.AppliedAt.Format(time.ANSIC)
+		}
+		baselined := ""
+		if st.Baselined {
+			baselined = " (baselined)"
+		}
+		fmt.Fprintf(w, "    %-24s -- %v%s%s\n", appliedAt, filepath.Base(st.Source), baselined, appliedByText(st))
+	}
+	return nil
+}
+
+// appliedByText describes who applied a migration, where and how long it
+// took, for the text status, or returns "" if that wasn't recorded.
+func appliedByText(st goosedb.MigrationStatus) string {
+	if by := ranBy(st.AppliedBy, st.Hostname, st.Duration); by != "" {
+		return "  by " + by
+	}
+	return ""
+}
+
+// ranBy returns "user@host in 1.2s", leaving out whatever wasn't recorded.
+func ranBy(user, host string, d time.Duration) string {
+	if user == "" && host == "" {
+		return ""
+	}
+	by := user
+	if host != "" {
+		by += "@" + host
+	}
+	if d > 0 {
+		by += fmt.Sprintf(" in %v", d)
+	}
+	return by
+}
+
+// historyEntry is the machine-readable record for one row of
+// `goose history`.
+type historyEntry struct {
+	Version      int64     `json:"version"`
+	Filename     string    `json:"filename"`
+	Direction    string    `json:"direction"`
+	Baselined    bool      `json:"baselined"`
+	At           time.Time `json:"at"`
+	DurationMS   *int64    `json:"duration_ms,omitempty"`
+	AppliedBy    string    `json:"applied_by,omitempty"`
+	Hostname     string    `json:"hostname,omitempty"`
+	GooseVersion string    `json:"goose_version,omitempty"`
+}
+
+// historyTSVHeader names the TSV history columns, one for each field of
+// historyEntry, with the same names as the JSON.
+const historyTSVHeader = "version\tfilename\tdirection\tat\tapplied_by\thostname\tduration_ms\tbaselined\tgoose_version\n"
+
+func writeHistory(w io.Writer, format, env string, entries []goosedb.HistoryEntry) error {
+	switch format {
+	case formatJSON:
+		out := struct {
+			Env     string         `json:"env"`
+			History []historyEntry `json:"history"`
+		}{Env: env, History: make([]historyEntry, 0, len(entries))}
+		for _, e := range entries {
+			he := historyEntry{Version: e.Version, Filename: e.Filename, Direction: e.Direction, Baselined: e.Baselined,
+				At: e.At, AppliedBy: e.AppliedBy, Hostname: e.Hostname, GooseVersion: e.GooseVersion}
+			if e.Duration > 0 {
+				d := e.Duration.Milliseconds()
+				he.DurationMS = &d
+			}
+			out.History = append(out.History, he)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+
+	case formatTSV:
+		if _, err := io.WriteString(w, historyTSVHeader); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			duration := ""
+			if e.Duration > 0 {
+				duration = fmt.Sprint(e.Duration.Milliseconds())
+			}
+			if _, err := fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%t\t%s\n", e.Version, e.Filename, e.Direction,
+				e.At.Format(time.RFC3339), tsvEscape(e.AppliedBy), tsvEscape(e.Hostname), duration, e.Baselined, tsvEscape(e.GooseVersion)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fmt.Fprintf(w, "goose: history for environment '%v'\n", env)
+	if len(entries) == 0 {
+		fmt.Fprintln(w, "    no migrations recorded")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "    At\tDirection\tVersion\tMigration\tBy")
+	for _, e := range entries {
+		direction := e.Direction
+		if e.Baselined {
+			direction = "baseline"
+		}
+		filename := e.Filename
+		if filename == "" {
+			filename = "-"
+		}
+		fmt.Fprintf(tw, "    %s\t%s\t%d\t%s\t%s\n", e.At.Format(time.ANSIC), direction, e.Version, filename, ranBy(e.AppliedBy, e.Hostname, e.Duration))
+	}
+	return tw.Flush()
+}
+
+func writeDBVersion(w io.Writer, format, env string, version int64) error {
+	switch format {
+	case formatJSON:
+		return json.NewEncoder(w).Encode(struct {
+			Env     string `json:"env"`
+			Version int64  `json:"version"`
+		}{env, version})
+	case formatTSV:
+		_, err := fmt.Fprintf(w, "env\tversion\n%s\t%d\n", env, version)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "goose: dbversion %v\n", version)
+	return err
+}
+
+// targetResult is the machine-readable record for one fan-out target.
+type targetResult struct {
+	Target     string  `json:"target"`
+	Before     *int64  `json:"before"`
+	After      *int64  `json:"after"`
+	Outcome    string  `json:"outcome"`
+	DurationMS float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+func newTargetResult(r goosedb.TargetResult) targetResult {
+	res := targetResult{
+		Target:     r.Target,
+		Outcome:    goosedb.OutcomeOK,
+		DurationMS: float64(r.Duration.Microseconds()) / 1000,
+	}
+	if r.Before >= 0 {
+		res.Before = &r.Before
+	}
+	if r.After >= 0 {
+		res.After = &r.After
+	}
+	switch {
+	case r.Skipped:
+		res.Outcome = "skipped"
+	case r.Err != nil:
+		res.Outcome = goosedb.OutcomeFail
+		res.Error = r.Err.Error()
+	}
+	return res
+}
+
+func writeTargetResults(w io.Writer, format string, results []goosedb.TargetResult) error {
+	if format == formatJSON {
+		enc := json.NewEncoder(w)
+		for _, r := range results {
+			if err := enc.Encode(newTargetResult(r)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	version := func(v *int64) string {
+		if v == nil {
+			return "-"
+		}
+		return fmt.Sprint(*v)
+	}
+	if format == formatTSV {
+		fmt.Fprintln(w, "target\tbefore\tafter\toutcome\terror")
+		for _, r := range results {
+			res := newTargetResult(r)
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", res.Target, version(res.Before), version(res.After), res.Outcome, tsvEscape(res.Error))
+		}
+		return nil
+	}
+
+	fmt.Fprintln(w, "goose: fan-out summary")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "    Target\tBefore\tAfter\tResult")
+	for _, r := range results {
+		res := newTargetResult(r)
+		result := res.Outcome
+		if res.Error != "" {
+			// a failed statement's error spans several lines
+			msg, _, _ := strings.Cut(res.Error, "\n")
+			result += ": " + msg
+		}
+		fmt.Fprintf(tw, "    %s\t%s\t%s\t%s\n", res.Target, version(res.Before), version(res.After), result)
+	}
+	return tw.Flush()
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -17,13 +19,40 @@ import (
 var flagPath = flag.String("path", "db", "folder containing db info")
 var flagEnv = flag.String("env", "development", "which DB environment to use")
 var flagPgSchema = flag.String("pgschema", "", "which postgres-schema to migrate (default = none)")
+var flagCreatePgSchema = flag.Bool("create-pgschema", false, "create the -pgschema schema if it doesn't exist")
 var flagVersion = flag.Bool("version", false, "print goose version")
+var flagTimeout = flag.Duration("timeout", 0, "cancel the command if it runs longer than this (default = no timeout)")
+var flagLock = flag.Bool("lock", false, "hold a migration lock so concurrent runs wait for each other")
+var flagLockTimeout = flag.Duration("lock-timeout", 0, "how long to wait for the migration lock (default = no limit)")
+var flagVersionTable = flag.String("version-table", "", "table that records applied migrations (default = goose_db_version, or versiontable in dbconf.yml)")
+var flagTrack = flag.String("track", "", "which migration track in dbconf.yml to run (default = the main migrations directory)")
+var flagVersionSchema = flag.String("version-schema", "", "schema holding the version table (default = none, or versionschema in dbconf.yml)")
 
 // helper to create a DBConf from the given flags
 func dbConfFromFlags() (*goosedb.DBConf, error) {
-	return goosedb.NewDBConf(*flagPath, *flagEnv, *flagPgSchema)
+	conf, err := goosedb.NewDBConf(*flagPath, *flagEnv, *flagPgSchema)
+	if err != nil {
+		return nil, err
+	}
+	if *flagCreatePgSchema {
+		conf.CreatePgSchema = true
+	}
+	conf.Lock = *flagLock
+	conf.LockTimeout = *flagLockTimeout
+	if *flagVersionTable != "" {
+		conf.VersionTable = *flagVersionTable
+	}
+	if *flagVersionSchema != "" {
+		conf.VersionSchema = *flagVersionSchema
+	}
+	if *flagTrack != "" {
+		return conf.ForTrack(*flagTrack)
+	}
+	return conf, nil
 }
 
+// commands are matched by prefix in this order, so new commands go after
+// the original ones to keep abbreviations such as "goose v" working.
 var commands = []*Command{
 	upCmd,
 	downCmd,
@@ -35,6 +64,17 @@ var commands = []*Command{
 	helpCmd,
 	initCmd,
 	printCmd,
+	upToCmd,
+	downToCmd,
+	upByOneCmd,
+	fanOutCmd,
+	baselineCmd,
+	validateCmd,
+	verifyCmd,
+	repairCmd,
+	historyCmd,
+	fixCmd,
+	unlockCmd,
 }
 
 var versionCmd = &Command{
@@ -76,7 +116,7 @@ var dbConfTpl = []byte(`# Database configuration file.
 #     open: $DATABASE_URL
 `)
 
-func initRun(*Command, ...string) {
+func initRun(context.Context, *Command, ...string) {
 	wd, err := os.Getwd()
 	if err != nil {
 		os.Stderr.WriteString(err.Error())
@@ -93,7 +133,7 @@ func initRun(*Command, ...string) {
 	}
 }
 
-func helpRun(*Command, ...string) {
+func helpRun(context.Context, *Command, ...string) {
 	flag.Usage()
 }
 
@@ -101,7 +141,7 @@ func printVersion(w io.Writer) {
 	fmt.Fprintf(w, "%s\n", goose.Version)
 }
 
-func versionRun(*Command, ...string) {
+func versionRun(context.Context, *Command, ...string) {
 	printVersion(os.Stdout)
 }
 
@@ -123,22 +163,39 @@ func main() {
 		return
 	}
 
-	var cmd *Command
 	name := args[0]
-	for _, c := range commands {
-		if strings.HasPrefix(c.Name, name) {
-			cmd = c
-			break
-		}
-	}
-
+	cmd := findCommand(name)
 	if cmd == nil {
 		printUnknownCommand(os.Stderr, name)
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	cmd.Exec(args[1:])
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *flagTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *flagTimeout)
+		defer cancel()
+	}
+
+	cmd.Exec(ctx, args[1:])
+}
+
+// findCommand returns the command called name, or else the first command
+// whose name starts with name, or nil.
+func findCommand(name string) *Command {
+	for _, c := range commands {
+		if c.Name == name {
+			return c
+		}
+	}
+	for _, c := range commands {
+		if strings.HasPrefix(c.Name, name) {
+			return c
+		}
+	}
+	return nil
 }
 
 func usage() {
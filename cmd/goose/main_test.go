@@ -2,9 +2,19 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kevinburke/goose/lib/goose"
+	"github.com/kevinburke/goose/lib/goosedb"
 )
 
 func TestPrintVersion(t *testing.T) {
@@ -22,3 +32,254 @@ func TestPrintUnknownCommand(t *testing.T) {
 		t.Fatalf("printUnknownCommand() = %q, want %q", got, want)
 	}
 }
+
+func TestFindCommand(t *testing.T) {
+	tests := map[string]string{
+		"u":         "up",
+		"up":        "up",
+		"up-to":     "up-to",
+		"d":         "down",
+		"r":         "redo",
+		"s":         "status",
+		"v":         "version",
+		"ver":       "version",
+		"veri":      "verify",
+		"va":        "validate",
+		"h":         "help",
+		"hi":        "history",
+		"f":         "fan-out",
+		"fi":        "fix",
+		"un":        "unlock",
+		"dbversion": "dbversion",
+	}
+	for name, want := range tests {
+		c := findCommand(name)
+		if c == nil || c.Name != want {
+			t.Errorf("findCommand(%q): got %v, want %s", name, c, want)
+		}
+	}
+	if c := findCommand("nosuchcommand"); c != nil {
+		t.Errorf("findCommand(nosuchcommand): got %s", c.Name)
+	}
+}
+
+func TestParseTargetVersion(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "002_two.sql"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		arg     string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"2", 2, false},
+		{"1", 0, true},
+		{"3", 0, true},
+		{"-1", 0, true},
+		{"two", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseTargetVersion(&goosedb.DBConf{MigrationsDir: dir}, tt.arg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTargetVersion(%q): got err %v, want error %t", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseTargetVersion(%q) = %d, want %d", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestTrackGoMigrationTarget(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"001_one.sql", "002_two.sql"} {
+		sql := "-- +goose Up\nCREATE TABLE t" + name[:3] + " (id int);\n-- +goose Down\nDROP TABLE t" + name[:3] + ";\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(sql), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	goose.ResetMigrations()
+	defer goose.ResetMigrations()
+	ran := false
+	goose.RegisterTrackMigration("reports", 5, "backfill", func(ctx context.Context, tx *sql.Tx) error {
+		ran = true
+		return nil
+	}, nil)
+	// a default-track migration doesn't move the reports track's target
+	goose.RegisterMigration(9, "other", nil, nil)
+
+	conf, err := goosedb.NewConfig("sqlite3", filepath.Join(t.TempDir(), "goose.db"), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Track = "reports"
+	conf.VersionTable = "goose_db_version_reports"
+
+	latest, err := latestVersion(conf)
+	if err != nil || latest != 5 {
+		t.Fatalf("latestVersion: got %d, %v, want 5", latest, err)
+	}
+	if v, err := parseTargetVersion(conf, "5"); err != nil || v != 5 {
+		t.Errorf("parseTargetVersion(5): got %d, %v", v, err)
+	}
+	if _, err := parseTargetVersion(conf, "9"); err == nil {
+		t.Error("parseTargetVersion(9): a default-track migration was accepted on the reports track")
+	}
+	if v, err := previousVersion(conf, 5); err != nil || v != 2 {
+		t.Errorf("previousVersion(5): got %d, %v, want 2", v, err)
+	}
+
+	if err := goosedb.RunMigrations(conf, conf.MigrationsDir, latest); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Error("up didn't run the track's Go migration")
+	}
+	if v, err := goosedb.GetDBVersion(conf); err != nil || v != 5 {
+		t.Errorf("version after up: got %d, %v, want 5", v, err)
+	}
+}
+
+func TestParseHistoryTime(t *testing.T) {
+	tests := []struct {
+		arg      string
+		endOfDay bool
+		want     time.Time
+		wantErr  bool
+	}{
+		{"2024-03-01", false, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2024-03-01", true, time.Date(2024, 3, 1, 23, 59, 59, 999999999, time.UTC), false},
+		{"2024-03-01T12:30:00+01:00", true, time.Date(2024, 3, 1, 11, 30, 0, 0, time.UTC), false},
+		{"yesterday", false, time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseHistoryTime(tt.arg, tt.endOfDay)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHistoryTime(%q): got err %v, want error %t", tt.arg, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseHistoryTime(%q, %t) = %v, want %v", tt.arg, tt.endOfDay, got, tt.want)
+		}
+	}
+}
+
+func TestWriteStatus(t *testing.T) {
+	appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	statuses := []goosedb.MigrationStatus{
+		{Version: 1, Source: "db/migrations/001_one.sql", Applied: true, Baselined: true, AppliedAt: appliedAt},
+		{Version: 2, Source: "db/migrations/002_two.sql"},
+	}
+
+	var buf bytes.Buffer
+	if err := writeStatus(&buf, formatTSV, "test", statuses); err != nil {
+		t.Fatal(err)
+	}
+	want := "version\tfilename\tapplied\tapplied_at\tbaselined\tduration_ms\tapplied_by\thostname\tgoose_version\n" +
+		"1\t001_one.sql\ttrue\t2024-01-02T03:04:05Z\ttrue\t\t\t\t\n" +
+		"2\t002_two.sql\tfalse\t\tfalse\t\t\t\t\n"
+	if got := buf.String(); got != want {
+		t.Errorf("tsv status: got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	if err := writeStatus(&buf, formatJSON, "test", statuses); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Env        string
+		Migrations []map[string]any
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Env != "test" || len(out.Migrations) != 2 {
+		t.Fatalf("unexpected json status: %s", buf.String())
+	}
+	if got := out.Migrations[0]["applied_at"]; got != "2024-01-02T03:04:05Z" {
+		t.Errorf("applied_at: got %v", got)
+	}
+	if got, ok := out.Migrations[1]["applied_at"]; !ok || got != nil {
+		t.Errorf("pending migration applied_at: got %v, want null", got)
+	}
+	if got := out.Migrations[0]["baselined"]; got != true {
+		t.Errorf("baselined: got %v", got)
+	}
+}
+
+// checkFormatsMatch checks that the TSV that write prints has a column for
+// each field of the first JSON record under key, and no others.
+func checkFormatsMatch(t *testing.T, key string, write func(w io.Writer, format string) error) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := write(&buf, formatJSON); err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatal(err)
+	}
+	var records []map[string]any
+	if err := json.Unmarshal(out[key], &records); err != nil {
+		t.Fatal(err)
+	}
+	var jsonFields []string
+	for k := range records[0] {
+		jsonFields = append(jsonFields, k)
+	}
+
+	buf.Reset()
+	if err := write(&buf, formatTSV); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	tsvFields := strings.Split(lines[0], "\t")
+	if got := len(strings.Split(lines[1], "\t")); got != len(tsvFields) {
+		t.Errorf("tsv row has %d columns, header has %d", got, len(tsvFields))
+	}
+
+	slices.Sort(jsonFields)
+	slices.Sort(tsvFields)
+	if !slices.Equal(jsonFields, tsvFields) {
+		t.Errorf("tsv columns %v, want the json fields %v", tsvFields, jsonFields)
+	}
+}
+
+func TestFormatsMatch(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	statuses := []goosedb.MigrationStatus{{
+		Version: 1, Source: "001_one.sql", Applied: true, AppliedAt: at,
+		Duration: 1500 * time.Millisecond, AppliedBy: "alice", Hostname: "build1", GooseVersion: "1.18.0",
+	}}
+	checkFormatsMatch(t, "migrations", func(w io.Writer, format string) error {
+		return writeStatus(w, format, "test", statuses)
+	})
+
+	entries := []goosedb.HistoryEntry{{
+		Version: 1, Filename: "001_one.sql", Direction: "up", At: at,
+		Duration: 1500 * time.Millisecond, AppliedBy: "alice", Hostname: "build1", GooseVersion: "1.18.0",
+	}}
+	checkFormatsMatch(t, "history", func(w io.Writer, format string) error {
+		return writeHistory(w, format, "test", entries)
+	})
+}
+
+func TestRunLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log := newRunLogger(&buf, formatJSON)
+	log.Info("goose: migrating db environment 'test'", "env", "test")
+	log.Info("OK    001_one.sql", "version", int64(1), "file", "001_one.sql",
+		"direction", "up", "duration", 1500*time.Microsecond, "outcome", goosedb.OutcomeOK)
+
+	want := `{"version":1,"filename":"001_one.sql","direction":"up","outcome":"ok","duration_ms":1.5}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("json run output: got %q, want %q", got, want)
+	}
+
+	if newRunLogger(&buf, formatText) != nil {
+		t.Error("text format should use goosedb's default logger")
+	}
+}
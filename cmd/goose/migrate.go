@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/kevinburke/goose/lib/goose"
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+// latestVersion returns the most recent version conf can migrate to: the
+// highest in its migrations directory or registered as a Go migration on its
+// track.
+func latestVersion(conf *goosedb.DBConf) (int64, error) {
+	return goose.GetMostRecentTrackDBVersion(conf.Track, conf.MigrationsDir)
+}
+
+// previousVersion returns the version before version among conf's
+// migrations.
+func previousVersion(conf *goosedb.DBConf, version int64) (int64, error) {
+	return goose.GetPreviousTrackDBVersion(conf.Track, conf.MigrationsDir, version)
+}
+
+// parseTargetVersion parses a version given on the command line and checks
+// that a migration with that version exists among conf's migrations. Version
+// 0, the state before any migration has run, is always accepted.
+func parseTargetVersion(conf *goosedb.DBConf, arg string) (int64, error) {
+	target, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || target < 0 {
+		return 0, fmt.Errorf("goose: invalid version %q", arg)
+	}
+	if target == 0 {
+		return 0, nil
+	}
+
+	migrations, err := goose.CollectTrackMigrations(conf.Track, conf.MigrationsDir, 0, target)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range migrations {
+		if m.Version == target {
+			return target, nil
+		}
+	}
+	return 0, fmt.Errorf("goose: no migration with version %d in %s", target, conf.MigrationsDir)
+}
+
+// dryRunUsage is the usage of the -dry-run flag shared by the commands that
+// run migrations.
+const dryRunUsage = "print the SQL that would run, annotated, without executing anything"
+
+// migrateTo prints the migrations that will run to move db to target and
+// then runs them.
+func migrateTo(ctx context.Context, conf *goosedb.DBConf, db *sql.DB, target int64) error {
+	if conf.DryRun != nil {
+		return goosedb.RunMigrationsOnDbContext(ctx, conf, conf.MigrationsDir, target, db)
+	}
+	plan, err := goosedb.PlanMigrationsOnDbContext(ctx, conf, conf.MigrationsDir, target, db)
+	if err != nil {
+		return err
+	}
+	if len(plan) > 0 {
+		fmt.Printf("goose: will run %d migration(s):\n", len(plan))
+		for _, m := range plan {
+			fmt.Println("     ", filepath.Base(m.Source))
+		}
+	}
+	return goosedb.RunMigrationsOnDbContext(ctx, conf, conf.MigrationsDir, target, db)
+}
@@ -0,0 +1,152 @@
+package goose
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// defaultSequentialWidth is the number of digits of the first sequential
+// version in a directory.
+const defaultSequentialWidth = 5
+
+// IsTimestampVersion reports whether v looks like a version generated by
+// CreateMigration, such as 20240102150405, rather than a sequential one.
+func IsTimestampVersion(v int64) bool {
+	_, err := time.Parse(timestampFormat, fmt.Sprint(v))
+	return err == nil
+}
+
+// nextSequentialVersion returns the version after the highest sequential
+// version in names or registered as a Go migration, leaving out those in
+// skip, and the number of digits to pad it to. Timestamped versions are
+// ignored, so the result is always a sequential version.
+func nextSequentialVersion(names []string, skip map[int64]bool) (next int64, width int, err error) {
+	vs, err := versions(names, DefaultTrack)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, v := range vs {
+		if !skip[v] && !IsTimestampVersion(v) && v > next {
+			next = v
+		}
+	}
+
+	width = defaultSequentialWidth
+	for _, name := range names {
+		if v, err := NumericComponent(name); err == nil && v == next {
+			width = len(numericPrefix(name))
+		}
+	}
+	return next + 1, width, nil
+}
+
+// numericPrefix returns the part of a migration file's name before the first
+// underscore.
+func numericPrefix(name string) string {
+	prefix, _, _ := strings.Cut(filepath.Base(name), "_")
+	return prefix
+}
+
+// ErrFixReorder is returned by FixMigrations when the sequential versions it
+// would give unapplied migrations sort before a version the database has
+// already applied, as when the applied history is timestamped.
+var ErrFixReorder = errors.New("goose: cannot renumber migrations without reordering applied history")
+
+// Renaming describes a migration file renamed by FixMigrations.
+type Renaming struct {
+	Version    int64
+	Source     string
+	NewVersion int64
+	NewSource  string
+}
+
+// PlanFixMigrations returns the renames FixMigrations would make in dirpath,
+// without making them.
+func PlanFixMigrations(dirpath string, applied map[int64]bool) ([]Renaming, error) {
+	names, err := listFiles(dirpath)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := collectMigrations(names, DefaultTrack, 0, math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+
+	// renumber from the first unapplied timestamped file on, so that files
+	// keep their order; applied migrations and Go migrations stay put
+	var pending []*Migration
+	for _, m := range migrations {
+		if m.Go != nil || applied[m.Version] {
+			continue
+		}
+		if len(pending) > 0 || IsTimestampVersion(m.Version) {
+			pending = append(pending, m)
+		}
+	}
+	skip := make(map[int64]bool, len(pending))
+	for _, m := range pending {
+		skip[m.Version] = true
+	}
+	next, width, err := nextSequentialVersion(names, skip)
+	if err != nil {
+		return nil, err
+	}
+	var lastApplied int64
+	for v := range applied {
+		if v > lastApplied {
+			lastApplied = v
+		}
+	}
+	if len(pending) > 0 && next <= lastApplied {
+		return nil, fmt.Errorf("%w: %s would become version %d, before applied version %d",
+			ErrFixReorder, filepath.Base(pending[0].Source), next, lastApplied)
+	}
+
+	var renames []Renaming
+	for _, m := range pending {
+		version := next
+		next++
+		if version == m.Version {
+			continue
+		}
+		base := filepath.Base(m.Source)
+		rest := strings.TrimPrefix(base, numericPrefix(base))
+		renames = append(renames, Renaming{
+			Version:    m.Version,
+			Source:     m.Source,
+			NewVersion: version,
+			NewSource:  filepath.Join(filepath.Dir(m.Source), fmt.Sprintf("%0*d%s", width, version, rest)),
+		})
+	}
+	return renames, nil
+}
+
+// FixMigrations renames the timestamped migrations in dirpath that haven't
+// been applied, along with any unapplied migrations after them, to the
+// sequential versions following the highest remaining sequential one, in
+// version order. This lets timestamps be used while developing on a branch
+// and sequential versions once merged. applied is the set of versions the
+// database has applied; those files are never renamed, since their recorded
+// versions must keep matching. If the new versions would sort before an
+// applied one, FixMigrations renames nothing and returns an error wrapping
+// ErrFixReorder. It returns the renames made.
+func FixMigrations(dirpath string, applied map[int64]bool) ([]Renaming, error) {
+	renames, err := PlanFixMigrations(dirpath, applied)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range renames {
+		if _, err := os.Stat(r.NewSource); err == nil {
+			return renames[:i], fmt.Errorf("goose: cannot rename %s: %s already exists", r.Source, r.NewSource)
+		}
+		if err := os.Rename(r.Source, r.NewSource); err != nil {
+			return renames[:i], err
+		}
+	}
+	return renames, nil
+}
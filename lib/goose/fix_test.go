@@ -0,0 +1,121 @@
+package goose
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("-- +goose Up\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func dirNames(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestCreateSequentialMigration(t *testing.T) {
+	dir := t.TempDir()
+	path, err := CreateSequentialMigration("first", "sql", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := filepath.Base(path); got != "00001_first.sql" {
+		t.Errorf("first migration: got %s", got)
+	}
+
+	// the width of the existing files wins
+	dir = t.TempDir()
+	writeFiles(t, dir, "001_basics.sql", "002_next.sql")
+	path, err = CreateSequentialMigration("third", "sql", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := filepath.Base(path); got != "003_third.sql" {
+		t.Errorf("next migration: got %s", got)
+	}
+
+	// timestamped versions are ignored, so the number stays sequential
+	writeFiles(t, dir, "20240102150405_branch.sql")
+	path, err = CreateSequentialMigration("fourth", "sql", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := filepath.Base(path); got != "004_fourth.sql" {
+		t.Errorf("migration after a timestamp: got %s", got)
+	}
+}
+
+func TestFixMigrations(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "001_basics.sql", "002_next.sql",
+		"20240102150405_add_users.sql", "20240101000000_add_teams.sql")
+
+	renames, err := PlanFixMigrations(dir, map[int64]bool{1: true, 2: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renames) != 2 || renames[0].Version != 20240101000000 || renames[0].NewVersion != 3 ||
+		filepath.Base(renames[1].NewSource) != "004_add_users.sql" {
+		t.Errorf("unexpected plan: %+v", renames)
+	}
+	if names := dirNames(t, dir); len(names) != 4 || names[2] != "20240101000000_add_teams.sql" {
+		t.Errorf("plan renamed files: %v", names)
+	}
+
+	if _, err := FixMigrations(dir, map[int64]bool{1: true, 2: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"001_basics.sql", "002_next.sql", "003_add_teams.sql", "004_add_users.sql"}
+	if names := dirNames(t, dir); !reflect.DeepEqual(names, want) {
+		t.Errorf("after fix: got %v, want %v", names, want)
+	}
+
+	renames, err = FixMigrations(dir, nil)
+	if err != nil || len(renames) != 0 {
+		t.Errorf("second fix: got %v, %v", renames, err)
+	}
+}
+
+func TestFixMigrationsTimestampedHistory(t *testing.T) {
+	// a project created before -seq existed, with nothing applied yet
+	dir := t.TempDir()
+	writeFiles(t, dir, "20230101000000_users.sql", "20230601000000_teams.sql")
+	if _, err := FixMigrations(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"00001_users.sql", "00002_teams.sql"}
+	if names := dirNames(t, dir); !reflect.DeepEqual(names, want) {
+		t.Errorf("after fix: got %v, want %v", names, want)
+	}
+
+	// once timestamps are applied, sequential versions would sort before
+	// them
+	dir = t.TempDir()
+	writeFiles(t, dir, "20230101000000_users.sql", "20230601000000_teams.sql", "20240102150405_latest.sql")
+	applied := map[int64]bool{20230101000000: true, 20230601000000: true}
+	renames, err := FixMigrations(dir, applied)
+	if !errors.Is(err, ErrFixReorder) || len(renames) != 0 {
+		t.Errorf("fix after applied timestamps: got %+v, %v, want ErrFixReorder", renames, err)
+	}
+	want = []string{"20230101000000_users.sql", "20230601000000_teams.sql", "20240102150405_latest.sql"}
+	if names := dirNames(t, dir); !reflect.DeepEqual(names, want) {
+		t.Errorf("refused fix renamed files: got %v", names)
+	}
+}
@@ -3,9 +3,10 @@ package goose
 import (
 	"errors"
 	"fmt"
-	"log"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
@@ -14,6 +15,10 @@ import (
 
 var (
 	ErrNoPreviousVersion = errors.New("no previous version found")
+
+	// ErrDuplicateVersion is returned when more than one file in a
+	// migrations directory specifies the same version.
+	ErrDuplicateVersion = errors.New("goose: more than one file specifies the same migration version")
 )
 
 type MigrationRecord struct {
@@ -26,48 +31,169 @@ type Migration struct {
 	Version  int64
 	Next     int64  // next version, or -1 if none
 	Previous int64  // previous version, -1 if none
-	Source   string // path to .go or .sql script
+	Source   string // path to .sql script, or name of a Go migration
+
+	Go *GoMigration // set for migrations registered with RegisterMigration
 }
 
 func newMigration(v int64, src string) *Migration {
-	return &Migration{v, -1, -1, src}
+	return &Migration{Version: v, Next: -1, Previous: -1, Source: src}
 }
 
 // CollectMigrations collects and returns all of the valid looking migration
-// scripts in dirpath. Set current to 0 and target to a very large number to
-// collect all migrations in the directory.
+// scripts in dirpath, along with any registered Go migrations, ordered by
+// version. Set current to 0 and target to a very large number to collect all
+// migrations in the directory.
 func CollectMigrations(dirpath string, current, target int64) ([]*Migration, error) {
+	names, err := listFiles(dirpath)
+	if err != nil {
+		return nil, err
+	}
+	return collectMigrations(names, DefaultTrack, current, target)
+}
+
+// CollectTrackMigrations is like CollectMigrations, but includes the Go
+// migrations registered for track instead of those on the default track.
+func CollectTrackMigrations(track, dirpath string, current, target int64) ([]*Migration, error) {
+	names, err := listFiles(dirpath)
+	if err != nil {
+		return nil, err
+	}
+	return collectMigrations(names, track, current, target)
+}
+
+// CollectMigrationsFS is like CollectMigrations but reads the directory
+// dirpath from fsys, such as an embed.FS. The Source of each migration is
+// its path within fsys.
+func CollectMigrationsFS(fsys fs.FS, dirpath string, current, target int64) ([]*Migration, error) {
+	names, err := listFilesFS(fsys, dirpath)
+	if err != nil {
+		return nil, err
+	}
+	return collectMigrations(names, DefaultTrack, current, target)
+}
+
+// CollectTrackMigrationsFS is like CollectTrackMigrations but reads the
+// directory dirpath from fsys.
+func CollectTrackMigrationsFS(track string, fsys fs.FS, dirpath string, current, target int64) ([]*Migration, error) {
+	names, err := listFilesFS(fsys, dirpath)
+	if err != nil {
+		return nil, err
+	}
+	return collectMigrations(names, track, current, target)
+}
+
+func collectMigrations(names []string, track string, current, target int64) ([]*Migration, error) {
 	// extract the numeric component of each migration,
 	// filter out any uninteresting files,
 	// and ensure we only have one file per migration version.
 	m := make([]*Migration, 0)
-	err := filepath.Walk(dirpath, func(name string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+	seen := make(map[int64]string)
+	for _, name := range names {
+		v, e := NumericComponent(name)
+		if e != nil {
+			continue
 		}
 
-		if v, e := NumericComponent(name); e == nil {
-
-			for _, g := range m {
-				if v == g.Version {
-					log.Fatalf("more than one file specifies the migration for version %d (%s and %s)",
-						v, g.Source, filepath.Join(dirpath, name))
-				}
-			}
+		if prev, ok := seen[v]; ok {
+			return nil, fmt.Errorf("%w: version %d (%s and %s)", ErrDuplicateVersion, v, prev, name)
+		}
+		seen[v] = name
 
-			if versionFilter(v, current, target) {
-				m = append(m, newMigration(v, name))
-			}
+		if versionFilter(v, current, target) {
+			m = append(m, newMigration(v, name))
 		}
+	}
 
-		return nil
-	})
+	registered, err := registeredMigrations(track)
 	if err != nil {
 		return nil, err
 	}
+	for _, g := range registered {
+		if prev, ok := seen[g.Version]; ok {
+			return nil, fmt.Errorf("%w: version %d (%s and %s)", ErrDuplicateVersion, g.Version, prev, g.Source)
+		}
+		if versionFilter(g.Version, current, target) {
+			m = append(m, g)
+		}
+	}
+	sort.SliceStable(m, func(i, j int) bool { return m[i].Version < m[j].Version })
 	return m, nil
 }
 
+// versions returns the version of every valid migration name in names and of
+// every Go migration registered on track.
+func versions(names []string, track string) ([]int64, error) {
+	var vs []int64
+	for _, name := range names {
+		if v, e := NumericComponent(name); e == nil {
+			vs = append(vs, v)
+		}
+	}
+	registered, err := registeredMigrations(track)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range registered {
+		vs = append(vs, g.Version)
+	}
+	return vs, nil
+}
+
+// MigrationFiles returns the path of every .sql file below dirpath, in
+// lexical order, including files whose names are not valid migration names.
+func MigrationFiles(dirpath string) ([]string, error) {
+	names, err := listFiles(dirpath)
+	return sqlFiles(names), err
+}
+
+// MigrationFilesFS is like MigrationFiles but walks dirpath in fsys.
+func MigrationFilesFS(fsys fs.FS, dirpath string) ([]string, error) {
+	names, err := listFilesFS(fsys, dirpath)
+	return sqlFiles(names), err
+}
+
+func sqlFiles(names []string) []string {
+	var files []string
+	for _, name := range names {
+		if filepath.Ext(name) == ".sql" {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// listFiles returns the path of every regular file below dirpath, in
+// lexical order.
+func listFiles(dirpath string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(dirpath, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names, err
+}
+
+// listFilesFS is like listFiles but walks dirpath in fsys.
+func listFilesFS(fsys fs.FS, dirpath string) ([]string, error) {
+	var names []string
+	err := fs.WalkDir(fsys, dirpath, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			names = append(names, name)
+		}
+		return nil
+	})
+	return names, err
+}
+
 // versionFilter returns true if v is greater than current and less than or
 // equal to target.
 func versionFilter(v, current, target int64) bool {
@@ -112,25 +238,52 @@ func NumericComponent(name string) (int64, error) {
 }
 
 func GetPreviousDBVersion(dirpath string, version int64) (previous int64, err error) {
+	return GetPreviousTrackDBVersion(DefaultTrack, dirpath, version)
+}
+
+// GetPreviousTrackDBVersion is like GetPreviousDBVersion, but counts the Go
+// migrations registered for track instead of those on the default track.
+func GetPreviousTrackDBVersion(track, dirpath string, version int64) (previous int64, err error) {
+	names, err := listFiles(dirpath)
+	if err != nil {
+		return -1, err
+	}
+	return previousVersion(names, track, version)
+}
+
+// GetPreviousDBVersionFS is like GetPreviousDBVersion but reads the
+// directory dirpath from fsys.
+func GetPreviousDBVersionFS(fsys fs.FS, dirpath string, version int64) (previous int64, err error) {
+	return GetPreviousTrackDBVersionFS(DefaultTrack, fsys, dirpath, version)
+}
+
+// GetPreviousTrackDBVersionFS is like GetPreviousTrackDBVersion but reads
+// the directory dirpath from fsys.
+func GetPreviousTrackDBVersionFS(track string, fsys fs.FS, dirpath string, version int64) (previous int64, err error) {
+	names, err := listFilesFS(fsys, dirpath)
+	if err != nil {
+		return -1, err
+	}
+	return previousVersion(names, track, version)
+}
+
+func previousVersion(names []string, track string, version int64) (previous int64, err error) {
+	vs, err := versions(names, track)
+	if err != nil {
+		return -1, err
+	}
 
 	previous = -1
 	sawGivenVersion := false
 
-	filepath.Walk(dirpath, func(name string, info os.FileInfo, walkerr error) error {
-
-		if !info.IsDir() {
-			if v, e := NumericComponent(name); e == nil {
-				if v > previous && v < version {
-					previous = v
-				}
-				if v == version {
-					sawGivenVersion = true
-				}
-			}
+	for _, v := range vs {
+		if v > previous && v < version {
+			previous = v
 		}
-
-		return nil
-	})
+		if v == version {
+			sawGivenVersion = true
+		}
+	}
 
 	if previous == -1 {
 		if sawGivenVersion {
@@ -149,24 +302,48 @@ func GetPreviousDBVersion(dirpath string, version int64) (previous int64, err er
 // helper to identify the most recent possible version
 // within a folder of migration scripts
 func GetMostRecentDBVersion(dirpath string) (version int64, err error) {
+	return GetMostRecentTrackDBVersion(DefaultTrack, dirpath)
+}
 
-	version = -1
+// GetMostRecentTrackDBVersion is like GetMostRecentDBVersion, but counts the
+// Go migrations registered for track instead of those on the default track.
+func GetMostRecentTrackDBVersion(track, dirpath string) (version int64, err error) {
+	names, err := listFiles(dirpath)
+	if err != nil {
+		return -1, err
+	}
+	return mostRecentVersion(names, track)
+}
 
-	filepath.Walk(dirpath, func(name string, info os.FileInfo, walkerr error) error {
-		if walkerr != nil {
-			return walkerr
-		}
+// GetMostRecentDBVersionFS is like GetMostRecentDBVersion but reads the
+// directory dirpath from fsys.
+func GetMostRecentDBVersionFS(fsys fs.FS, dirpath string) (version int64, err error) {
+	return GetMostRecentTrackDBVersionFS(DefaultTrack, fsys, dirpath)
+}
 
-		if !info.IsDir() {
-			if v, e := NumericComponent(name); e == nil {
-				if v > version {
-					version = v
-				}
-			}
-		}
+// GetMostRecentTrackDBVersionFS is like GetMostRecentTrackDBVersion but
+// reads the directory dirpath from fsys.
+func GetMostRecentTrackDBVersionFS(track string, fsys fs.FS, dirpath string) (version int64, err error) {
+	names, err := listFilesFS(fsys, dirpath)
+	if err != nil {
+		return -1, err
+	}
+	return mostRecentVersion(names, track)
+}
 
-		return nil
-	})
+func mostRecentVersion(names []string, track string) (version int64, err error) {
+	vs, err := versions(names, track)
+	if err != nil {
+		return -1, err
+	}
+
+	version = -1
+
+	for _, v := range vs {
+		if v > version {
+			version = v
+		}
+	}
 
 	if version == -1 {
 		err = errors.New("no valid version found")
@@ -175,25 +352,80 @@ func GetMostRecentDBVersion(dirpath string) (version int64, err error) {
 	return
 }
 
+// timestampFormat is the layout of the versions CreateMigration generates.
+const timestampFormat = "20060102150405"
+
 // CreateMigration creates a new migration and writes it to a new file in dir.
 // The path to the file will be returned.
 func CreateMigration(name, migrationType, dir string, t time.Time) (path string, err error) {
+	return CreateMigrationWithOptions(name, migrationType, dir, CreateOptions{Time: t})
+}
+
+// CreateSequentialMigration is like CreateMigration, but numbers the new
+// migration one after the highest sequential version in dir, zero-padded to
+// the width of that migration's file, or to five digits if there are none.
+// Timestamped versions are ignored; run FixMigrations to renumber unapplied
+// timestamped files first.
+func CreateSequentialMigration(name, migrationType, dir string) (path string, err error) {
+	return CreateMigrationWithOptions(name, migrationType, dir, CreateOptions{Sequential: true})
+}
+
+// CreateOptions configures CreateMigrationWithOptions.
+type CreateOptions struct {
+	// Time is the timestamp used as the version, the current UTC time if
+	// zero. It is ignored if Sequential is set.
+	Time time.Time
+
+	// Sequential numbers the migration like CreateSequentialMigration.
+	Sequential bool
+
+	// Template is rendered with a TemplateData to produce the file, the
+	// built-in template if nil. See LoadTemplate.
+	Template *template.Template
+
+	// Author and Env are passed to Template.
+	Author string
+	Env    string
+}
+
+// CreateMigrationWithOptions creates a new migration in dir as configured by
+// opts, and returns the path to the file.
+func CreateMigrationWithOptions(name, migrationType, dir string, opts CreateOptions) (path string, err error) {
 
 	if migrationType != "sql" {
 		return "", errors.New("migration type must be 'sql'")
 	}
 
-	timestamp := t.Format("20060102150405")
-	filename := fmt.Sprintf("%v_%v.%v", timestamp, name, migrationType)
+	var version string
+	if opts.Sequential {
+		names, err := listFiles(dir)
+		if err != nil {
+			return "", err
+		}
+		next, width, err := nextSequentialVersion(names, nil)
+		if err != nil {
+			return "", err
+		}
+		version = fmt.Sprintf("%0*d", width, next)
+	} else {
+		t := opts.Time
+		if t.IsZero() {
+			t = time.Now().UTC()
+		}
+		version = t.Format(timestampFormat)
+	}
+
+	filename := fmt.Sprintf("%v_%v.%v", version, name, migrationType)
 
 	fpath := filepath.Join(dir, filename)
 
-	var tmpl *template.Template
-	if migrationType == "sql" {
+	tmpl := opts.Template
+	if tmpl == nil {
 		tmpl = sqlMigrationTemplate
 	}
 
-	path, err = writeTemplateToFile(fpath, tmpl, timestamp)
+	data := TemplateData{Name: name, Version: version, Author: opts.Author, Env: opts.Env}
+	path, err = writeTemplateToFile(fpath, tmpl, data)
 
 	return
 }
@@ -0,0 +1,40 @@
+package goose
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCollectMigrationsFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/migrations/001_one.sql":   {},
+		"db/migrations/002_two.sql":   {},
+		"db/migrations/003_three.sql": {},
+		"db/migrations/README.md":     {},
+		"db/dbconf.yml":               {},
+	}
+
+	ms, err := CollectMigrationsFS(fsys, "db/migrations", 1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 2 || ms[0].Source != "db/migrations/002_two.sql" || ms[1].Version != 3 {
+		t.Errorf("unexpected migrations: %+v %+v", ms[0], ms[1])
+	}
+
+	latest, err := GetMostRecentDBVersionFS(fsys, "db/migrations")
+	if err != nil || latest != 3 {
+		t.Errorf("GetMostRecentDBVersionFS: got %d, %v", latest, err)
+	}
+
+	previous, err := GetPreviousDBVersionFS(fsys, "db/migrations", 3)
+	if err != nil || previous != 2 {
+		t.Errorf("GetPreviousDBVersionFS: got %d, %v", previous, err)
+	}
+
+	fsys["db/migrations/002_dup.sql"] = &fstest.MapFile{}
+	if _, err := CollectMigrationsFS(fsys, "db/migrations", 0, 3); !errors.Is(err, ErrDuplicateVersion) {
+		t.Errorf("expected ErrDuplicateVersion, got %v", err)
+	}
+}
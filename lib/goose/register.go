@@ -0,0 +1,131 @@
+package goose
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// GoMigrationFunc is one direction of a Go migration that runs in the same
+// transaction as the version table insert that records it.
+type GoMigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+// GoMigrationNoTxFunc is one direction of a Go migration that runs outside a
+// transaction, for work like chunked updates that commits as it goes. The
+// version is recorded after it returns successfully.
+type GoMigrationNoTxFunc func(ctx context.Context, db *sql.DB) error
+
+// GoMigration is a migration written in Go and registered with
+// RegisterMigration or RegisterMigrationNoTx. A nil function for a direction
+// does nothing but record the version.
+type GoMigration struct {
+	Up   GoMigrationFunc
+	Down GoMigrationFunc
+
+	// UpNoTx and DownNoTx are set instead of Up and Down for migrations
+	// registered with RegisterMigrationNoTx.
+	UpNoTx   GoMigrationNoTxFunc
+	DownNoTx GoMigrationNoTxFunc
+
+	NoTx bool
+}
+
+// DefaultTrack is the track of migrations registered with RegisterMigration,
+// and of a DBConf with no track selected.
+const DefaultTrack = ""
+
+// registry holds the registered Go migrations, keyed by track and version.
+var registry struct {
+	mu         sync.Mutex
+	migrations map[string]map[int64]*Migration
+	err        error // the first duplicate registration, if any
+}
+
+// RegisterMigration registers a Go migration with the given version. It runs
+// alongside the SQL migrations in every directory, ordered by version, and
+// is recorded in goose_db_version like them. name describes the migration;
+// its Source, which status shows and the version table records, is
+// "<version>_<name>.go".
+//
+// RegisterMigration is meant to be called from an init function in a
+// program built around lib/goosedb. Registering a version twice, or a
+// version that a migration file also uses, makes collecting migrations fail
+// with ErrDuplicateVersion.
+func RegisterMigration(version int64, name string, up, down GoMigrationFunc) {
+	register(DefaultTrack, version, name, &GoMigration{Up: up, Down: down})
+}
+
+// RegisterMigrationNoTx is like RegisterMigration, but up and down run
+// outside a transaction.
+func RegisterMigrationNoTx(version int64, name string, up, down GoMigrationNoTxFunc) {
+	register(DefaultTrack, version, name, &GoMigration{UpNoTx: up, DownNoTx: down, NoTx: true})
+}
+
+// RegisterTrackMigration is like RegisterMigration, but the migration runs
+// with the migrations of the named track instead of the default one. Its
+// version only needs to be unique within the track.
+func RegisterTrackMigration(track string, version int64, name string, up, down GoMigrationFunc) {
+	register(track, version, name, &GoMigration{Up: up, Down: down})
+}
+
+// RegisterTrackMigrationNoTx is like RegisterTrackMigration, but up and down
+// run outside a transaction.
+func RegisterTrackMigrationNoTx(track string, version int64, name string, up, down GoMigrationNoTxFunc) {
+	register(track, version, name, &GoMigration{UpNoTx: up, DownNoTx: down, NoTx: true})
+}
+
+func register(track string, version int64, name string, g *GoMigration) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	source := fmt.Sprintf("%d_%s.go", version, name)
+	if version <= 0 {
+		if registry.err == nil {
+			registry.err = fmt.Errorf("goose: Go migration %s: migration IDs must be greater than zero", source)
+		}
+		return
+	}
+	if prev, ok := registry.migrations[track][version]; ok {
+		if registry.err == nil {
+			registry.err = fmt.Errorf("%w: version %d (%s and %s)", ErrDuplicateVersion, version, prev.Source, source)
+		}
+		return
+	}
+	if registry.migrations == nil {
+		registry.migrations = make(map[string]map[int64]*Migration)
+	}
+	if registry.migrations[track] == nil {
+		registry.migrations[track] = make(map[int64]*Migration)
+	}
+	m := newMigration(version, source)
+	m.Go = g
+	registry.migrations[track][version] = m
+}
+
+// ResetMigrations removes every registered Go migration. It is meant for
+// tests.
+func ResetMigrations() {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	registry.migrations = nil
+	registry.err = nil
+}
+
+// registeredMigrations returns a copy of each Go migration registered on
+// track, in version order.
+func registeredMigrations(track string) ([]*Migration, error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if registry.err != nil {
+		return nil, registry.err
+	}
+	ms := make([]*Migration, 0, len(registry.migrations[track]))
+	for _, m := range registry.migrations[track] {
+		c := *m
+		ms = append(ms, &c)
+	}
+	sort.Slice(ms, func(i, j int) bool { return ms[i].Version < ms[j].Version })
+	return ms, nil
+}
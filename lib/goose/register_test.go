@@ -0,0 +1,59 @@
+package goose
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRegisterMigration(t *testing.T) {
+	t.Cleanup(ResetMigrations)
+	noop := func(context.Context, *sql.Tx) error { return nil }
+	RegisterMigration(2, "backfill", noop, noop)
+	RegisterMigrationNoTx(4, "chunked", nil, nil)
+
+	fsys := fstest.MapFS{
+		"migrations/001_one.sql":   {},
+		"migrations/003_three.sql": {},
+	}
+	ms, err := CollectMigrationsFS(fsys, "migrations", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range ms {
+		got = append(got, m.Source)
+	}
+	want := []string{"migrations/001_one.sql", "2_backfill.go", "migrations/003_three.sql", "4_chunked.go"}
+	if len(got) != len(want) {
+		t.Fatalf("got migrations %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("migration %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+	if ms[1].Go == nil || ms[1].Go.NoTx || ms[3].Go == nil || !ms[3].Go.NoTx {
+		t.Errorf("unexpected Go migrations: %+v %+v", ms[1].Go, ms[3].Go)
+	}
+
+	if latest, err := GetMostRecentDBVersionFS(fsys, "migrations"); err != nil || latest != 4 {
+		t.Errorf("GetMostRecentDBVersionFS: got %d, %v", latest, err)
+	}
+	if previous, err := GetPreviousDBVersionFS(fsys, "migrations", 3); err != nil || previous != 2 {
+		t.Errorf("GetPreviousDBVersionFS: got %d, %v", previous, err)
+	}
+
+	fsys["migrations/004_dup.sql"] = &fstest.MapFile{}
+	if _, err := CollectMigrationsFS(fsys, "migrations", 0, 10); !errors.Is(err, ErrDuplicateVersion) {
+		t.Errorf("expected ErrDuplicateVersion for a file, got %v", err)
+	}
+
+	delete(fsys, "migrations/004_dup.sql")
+	RegisterMigration(2, "again", noop, noop)
+	if _, err := CollectMigrationsFS(fsys, "migrations", 0, 10); !errors.Is(err, ErrDuplicateVersion) {
+		t.Errorf("expected ErrDuplicateVersion for a registration, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package goose
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// DefaultTemplateName is the template LoadTemplate picks from a templates
+// directory when no name is given.
+const DefaultTemplateName = "default"
+
+// TemplateData is what a migration template is rendered with. A template
+// might start with:
+//
+//	-- {{.Name}}, version {{.Version}}, by {{.Author}}
+//	-- +goose Up
+type TemplateData struct {
+	Name    string // the name given to create
+	Version string // the version prefix of the file name
+	Author  string
+	Env     string // the dbconf.yml environment
+}
+
+// LoadTemplate parses the migration template called name from dir, the file
+// <name>.sql in it. name may also be the path to a .sql file anywhere.
+//
+// If name is empty, LoadTemplate returns the template called "default" if
+// dir has one, or nil, meaning the built-in template, if it does not.
+func LoadTemplate(dir, name string) (*template.Template, error) {
+	path := filepath.Join(dir, name+".sql")
+	if filepath.Ext(name) == ".sql" {
+		path = name
+	}
+	if name == "" {
+		path = filepath.Join(dir, DefaultTemplateName+".sql")
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if name == "" {
+			return nil, nil
+		}
+		names, _ := TemplateNames(dir)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("goose: no template %q in %s", name, dir)
+		}
+		return nil, fmt.Errorf("goose: no template %q in %s; available: %s", name, dir, strings.Join(names, ", "))
+	}
+	t, err := template.New(filepath.Base(path)).Parse(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("goose: parsing template %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// TemplateNames returns the name of every migration template in dir, sorted.
+// A directory that does not exist has none.
+func TemplateNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && filepath.Ext(e.Name()) == ".sql" {
+			names = append(names, strings.TrimSuffix(e.Name(), ".sql"))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,62 @@
+package goose
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateMigrationTemplate(t *testing.T) {
+	templates := t.TempDir()
+	body := "-- {{.Name}} {{.Version}} by {{.Author}} for {{.Env}}\n-- +goose Up\n"
+	if err := os.WriteFile(filepath.Join(templates, "table.sql"), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// without a default template, the built-in one is used
+	tmpl, err := LoadTemplate(templates, "")
+	if err != nil || tmpl != nil {
+		t.Fatalf("LoadTemplate default: got %v, %v", tmpl, err)
+	}
+	if _, err := LoadTemplate(templates, "index"); err == nil || !strings.Contains(err.Error(), "available: table") {
+		t.Errorf("LoadTemplate unknown: got %v", err)
+	}
+
+	tmpl, err = LoadTemplate(templates, "table")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path, err := CreateMigrationWithOptions("add_users", "sql", dir, CreateOptions{
+		Time:     time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		Template: tmpl,
+		Author:   "alice",
+		Env:      "development",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-- add_users 20240102150405 by alice for development\n-- +goose Up\n"
+	if filepath.Base(path) != "20240102150405_add_users.sql" || string(contents) != want {
+		t.Errorf("got %s:\n%s", path, contents)
+	}
+
+	// a template that fails to render leaves no file behind
+	broken, err := LoadTemplate(templates, filepath.Join(templates, "table.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken, _ = broken.Parse("{{.Missing}}")
+	if _, err := CreateMigrationWithOptions("broken", "sql", dir, CreateOptions{Sequential: true, Template: broken}); err == nil {
+		t.Error("expected an error rendering an unknown field")
+	}
+	if names := dirNames(t, dir); len(names) != 1 {
+		t.Errorf("files after failed render: %v", names)
+	}
+}
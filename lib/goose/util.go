@@ -1,19 +1,27 @@
 package goose
 
 import (
+	"bytes"
 	"os"
 	"text/template"
 )
 
 // common routines
 
+// writeTemplateToFile renders t with data and writes it to a new file at
+// path. Nothing is written if t fails to render.
 func writeTemplateToFile(path string, t *template.Template, data any) (string, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return "", err
+	}
+
 	f, e := os.Create(path)
 	if e != nil {
 		return "", e
 	}
-
-	if err := t.Execute(f, data); err != nil {
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
 		return "", err
 	}
 	if err := f.Close(); err != nil {
@@ -0,0 +1,102 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// ErrVersionTableHasHistory is returned by Baseline when the version table
+// already records migrations and force is not set.
+var ErrVersionTableHasHistory = errors.New("goosedb: version table already has history")
+
+// Baseline records every migration in migrationsDir up to and including
+// version as applied, without running any of them, for adopting a database
+// whose schema already exists. The version table is created if it doesn't
+// exist, and the rows are flagged as baselined.
+//
+// Baseline refuses with ErrVersionTableHasHistory if any migration has
+// already been recorded, unless force is set, in which case only the
+// migrations up to version that aren't applied are recorded. It returns the
+// number of migrations recorded.
+func Baseline(conf *DBConf, migrationsDir string, version int64, db *sql.DB, force bool) (int, error) {
+	return BaselineContext(context.Background(), conf, migrationsDir, version, db, force)
+}
+
+// BaselineContext is like Baseline but uses ctx for every query.
+func BaselineContext(ctx context.Context, conf *DBConf, migrationsDir string, version int64, db *sql.DB, force bool) (int, error) {
+	if conf.Lock {
+		release, err := acquireLock(ctx, conf, db)
+		if err != nil {
+			return 0, err
+		}
+		defer release()
+	}
+
+	all, err := conf.collectMigrations(migrationsDir, 0, maxVersion)
+	if err != nil {
+		return 0, err
+	}
+	found := false
+	for _, m := range all {
+		if m.Version == version {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return 0, fmt.Errorf("goosedb: no migration with version %d in %s", version, migrationsDir)
+	}
+
+	if _, err := EnsureDBVersionContext(ctx, conf, db); err != nil {
+		return 0, err
+	}
+	records, err := appliedRecords(ctx, conf, db)
+	if err != nil {
+		return 0, err
+	}
+	if len(records) > 0 && !force {
+		return 0, fmt.Errorf("%w: %d migration(s) are applied; rerun with force to baseline anyway",
+			ErrVersionTableHasHistory, len(records))
+	}
+	applied := make(map[int64]bool, len(records))
+	for _, r := range records {
+		applied[r.version] = true
+	}
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer txn.Rollback()
+
+	stmt := conf.insertVersionSql()
+	recorded := 0
+	for _, m := range all {
+		if m.Version > version || applied[m.Version] {
+			continue
+		}
+		// record the checksum of files so that verify covers them
+		record := versionRecord{version: m.Version, applied: true, filename: filepath.Base(m.Source), baselined: true}
+		if m.Go == nil {
+			contents, err := conf.readMigration(m.Source)
+			if err != nil {
+				return 0, err
+			}
+			record.checksum = checksumOf(contents)
+		}
+		if _, err := txn.ExecContext(ctx, stmt, record.args(conf)...); err != nil {
+			return 0, fmt.Errorf("goosedb: baselining version %d: %w", m.Version, err)
+		}
+		recorded++
+	}
+	if err := txn.Commit(); err != nil {
+		return 0, err
+	}
+
+	conf.logger().InfoContext(ctx, fmt.Sprintf("goose: baselined %d migration(s) at version %d", recorded, version),
+		"env", conf.Env, "version", version, "count", recorded)
+	return recorded, nil
+}
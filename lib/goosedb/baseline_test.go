@@ -0,0 +1,57 @@
+package goosedb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBaseline(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql":   tableMigration("one"),
+		"002_two.sql":   tableMigration("two"),
+		"003_three.sql": tableMigration("three"),
+	})
+
+	if _, err := Baseline(conf, conf.MigrationsDir, 4, db, false); err == nil {
+		t.Error("expected an error baselining a version with no migration")
+	}
+
+	n, err := Baseline(conf, conf.MigrationsDir, 2, db, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Baseline: recorded %d, want 2", n)
+	}
+
+	// nothing ran
+	if _, err := db.Exec("SELECT 1 FROM one"); err == nil {
+		t.Error("baseline ran migration 1")
+	}
+
+	statuses, err := GetMigrationStatus(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Baselined || !statuses[1].Baselined || statuses[2].Applied {
+		t.Errorf("unexpected status after baseline: %+v", statuses)
+	}
+	if problems, err := VerifyChecksums(conf, conf.MigrationsDir, db); err != nil || len(problems) != 0 {
+		t.Errorf("VerifyChecksums: got %v, %v", problems, err)
+	}
+
+	// up only runs what comes after the baseline
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 3, db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("SELECT 1 FROM three"); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := Baseline(conf, conf.MigrationsDir, 3, db, false); !errors.Is(err, ErrVersionTableHasHistory) {
+		t.Errorf("expected ErrVersionTableHasHistory, got %v", err)
+	}
+	if n, err := Baseline(conf, conf.MigrationsDir, 3, db, true); err != nil || n != 0 {
+		t.Errorf("forced Baseline: got %d, %v, want 0", n, err)
+	}
+}
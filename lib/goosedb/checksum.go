@@ -0,0 +1,221 @@
+package goosedb
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/kevinburke/goose/lib/goose"
+)
+
+// checksumOf returns the checksum goose records for a migration's contents:
+// the hex-encoded SHA-256 of the file.
+func checksumOf(contents []byte) string {
+	sum := sha256.Sum256(contents)
+	return hex.EncodeToString(sum[:])
+}
+
+// removedChecksum is recorded by RepairChecksums for an applied migration
+// whose file was deleted. It can't be the checksum of any file.
+const removedChecksum = ""
+
+// ChecksumProblem describes an applied migration whose file no longer
+// matches what was applied.
+type ChecksumProblem struct {
+	Version  int64
+	Filename string // as recorded when the migration was applied
+	Source   string // path to the current file, or "" if it is missing
+
+	Recorded string // checksum recorded when the migration was applied
+	Current  string // checksum of the current file, or "" if it is missing
+}
+
+func (p ChecksumProblem) String() string {
+	if p.Source == "" {
+		return fmt.Sprintf("version %d (%s): applied migration file is missing", p.Version, p.Filename)
+	}
+	return fmt.Sprintf("version %d (%s): file has changed since it was applied", p.Version, filepath.Base(p.Source))
+}
+
+// ChecksumError is returned by a run with DBConf.VerifyChecksums set when
+// applied migrations have changed or disappeared.
+type ChecksumError struct {
+	Problems []ChecksumProblem
+}
+
+func (e *ChecksumError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "goosedb: %d applied migration(s) do not match their files", len(e.Problems))
+	for _, p := range e.Problems {
+		b.WriteString("\n\t")
+		b.WriteString(p.String())
+	}
+	b.WriteString("\nrun repair to accept the current files")
+	return b.String()
+}
+
+// appliedRecord is the latest version table row for an applied version.
+type appliedRecord struct {
+	id       int64
+	version  int64
+	filename sql.NullString
+	checksum sql.NullString
+}
+
+// appliedRecords returns the latest row for every applied version other than
+// the initial version 0, oldest version first.
+func appliedRecords(ctx context.Context, conf *DBConf, db *sql.DB) ([]appliedRecord, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, version_id, is_applied, filename, checksum FROM "+conf.versionTable()+" ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[int64]bool)
+	var records []appliedRecord
+	for rows.Next() {
+		var r appliedRecord
+		var applied bool
+		if err := rows.Scan(&r.id, &r.version, &applied, &r.filename, &r.checksum); err != nil {
+			return nil, fmt.Errorf("error scanning rows: %w", err)
+		}
+		if seen[r.version] {
+			continue
+		}
+		seen[r.version] = true
+		if applied && r.version != 0 {
+			records = append(records, r)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// rows came newest first; report in version order
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+	return records, nil
+}
+
+// VerifyChecksums compares every applied migration with its file in
+// migrationsDir and returns the ones that have changed or disappeared.
+// Migrations applied before goose recorded checksums are only checked for a
+// missing file until RepairChecksums records a checksum for them. Missing
+// files whose removal RepairChecksums accepted are skipped.
+func VerifyChecksums(conf *DBConf, migrationsDir string, db *sql.DB) ([]ChecksumProblem, error) {
+	return VerifyChecksumsContext(context.Background(), conf, migrationsDir, db)
+}
+
+// VerifyChecksumsContext is like VerifyChecksums but uses ctx for every
+// query.
+func VerifyChecksumsContext(ctx context.Context, conf *DBConf, migrationsDir string, db *sql.DB) ([]ChecksumProblem, error) {
+	var problems []ChecksumProblem
+	err := walkAppliedChecksums(ctx, conf, migrationsDir, db, func(r appliedRecord, source, current string) error {
+		switch {
+		case source == "":
+			// missing, whenever it was applied, unless its removal was
+			// accepted
+			if r.checksum.Valid && r.checksum.String == removedChecksum {
+				return nil
+			}
+		case !r.checksum.Valid:
+			// applied before goose recorded checksums, or a Go migration
+			return nil
+		case r.checksum.String == current:
+			return nil
+		}
+		problems = append(problems, ChecksumProblem{
+			Version:  r.version,
+			Filename: r.filename.String,
+			Source:   source,
+			Recorded: r.checksum.String,
+			Current:  current,
+		})
+		return nil
+	})
+	return problems, err
+}
+
+// RepairChecksums records the current checksum and filename of every applied
+// migration, accepting any changes to their files. Applied migrations whose
+// files are missing are recorded as removed, so that verifying no longer
+// reports them. It returns the number of migrations whose records were
+// updated.
+func RepairChecksums(conf *DBConf, migrationsDir string, db *sql.DB) (int, error) {
+	return RepairChecksumsContext(context.Background(), conf, migrationsDir, db)
+}
+
+// RepairChecksumsContext is like RepairChecksums but uses ctx for every
+// query.
+func RepairChecksumsContext(ctx context.Context, conf *DBConf, migrationsDir string, db *sql.DB) (int, error) {
+	updated := 0
+	stmt := conf.Driver.Dialect.UpdateChecksumSql(conf.versionTable())
+	err := walkAppliedChecksums(ctx, conf, migrationsDir, db, func(r appliedRecord, source, current string) error {
+		if source == "" {
+			if r.checksum.Valid && r.checksum.String == removedChecksum {
+				return nil
+			}
+			if _, err := db.ExecContext(ctx, stmt, r.filename, removedChecksum, r.id); err != nil {
+				return err
+			}
+			updated++
+			return nil
+		}
+		filename := filepath.Base(source)
+		if r.checksum.String == current && r.filename.String == filename {
+			return nil
+		}
+		if _, err := db.ExecContext(ctx, stmt, filename, current, r.id); err != nil {
+			return err
+		}
+		updated++
+		return nil
+	})
+	return updated, err
+}
+
+// walkAppliedChecksums calls fn for every applied migration with the path
+// and checksum of its current file, which are empty if it is missing.
+func walkAppliedChecksums(ctx context.Context, conf *DBConf, migrationsDir string, db *sql.DB,
+	fn func(r appliedRecord, source, current string) error) error {
+	if _, err := EnsureDBVersionContext(ctx, conf, db); err != nil {
+		return err
+	}
+	records, err := appliedRecords(ctx, conf, db)
+	if err != nil {
+		return err
+	}
+	migrations, err := conf.collectMigrations(migrationsDir, 0, maxVersion)
+	if err != nil {
+		return err
+	}
+	byVersion := make(map[int64]*goose.Migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.Version] = m
+	}
+
+	for _, r := range records {
+		source, current := "", ""
+		m := byVersion[r.version]
+		if m != nil {
+			source = m.Source
+		}
+		// Go migrations have no file to check
+		if m != nil && m.Go == nil {
+			contents, err := conf.readMigration(source)
+			if err != nil {
+				return err
+			}
+			current = checksumOf(contents)
+		}
+		if err := fn(r, source, current); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package goosedb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyAndRepairChecksums(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"002_two.sql": tableMigration("two"),
+	})
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := VerifyChecksums(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	writeMigration(t, conf, "001_one.sql", tableMigration("one")+"-- edited\n")
+	problems, err = VerifyChecksums(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 1 || problems[0].Version != 1 || problems[0].Source == "" {
+		t.Fatalf("expected version 1 to have changed, got %v", problems)
+	}
+
+	conf.VerifyChecksums = true
+	var cerr *ChecksumError
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db); !errors.As(err, &cerr) {
+		t.Fatalf("expected ChecksumError, got %v", err)
+	}
+
+	n, err := RepairChecksums(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("RepairChecksums: updated %d, want 1", n)
+	}
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepairMissingMigration(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"002_two.sql": tableMigration("two"),
+	})
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db); err != nil {
+		t.Fatal(err)
+	}
+
+	// rows from before goose recorded checksums: one whose file is still
+	// there, which can't be checked, and one whose file is gone
+	writeMigration(t, conf, "003_three.sql", tableMigration("three"))
+	if _, err := db.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (3, 1), (4, 1)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(conf.MigrationsDir, "002_two.sql")); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := VerifyChecksums(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 || problems[0].Version != 2 || problems[0].Source != "" ||
+		problems[1].Version != 4 || problems[1].Source != "" {
+		t.Fatalf("expected versions 2 and 4 to be missing, got %v", problems)
+	}
+
+	n, err := RepairChecksums(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("RepairChecksums: updated %d, want 3", n)
+	}
+	problems, err = VerifyChecksums(conf, conf.MigrationsDir, db)
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("after repair: got %v, %v", problems, err)
+	}
+	if n, err := RepairChecksums(conf, conf.MigrationsDir, db); err != nil || n != 0 {
+		t.Errorf("second repair: updated %d, %v", n, err)
+	}
+
+	// a file that comes back is verified again
+	writeMigration(t, conf, "002_two.sql", tableMigration("two"))
+	problems, err = VerifyChecksums(conf, conf.MigrationsDir, db)
+	if err != nil || len(problems) != 1 || problems[0].Version != 2 {
+		t.Errorf("after restoring the file: got %v, %v", problems, err)
+	}
+}
+
+func TestUpgradeVersionTable(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+	})
+
+	// a version table created by an older goose
+	if _, err := db.Exec(conf.Driver.Dialect.CreateVersionTableSql(conf.versionTable())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, 1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+	var filename, checksum string
+	err := db.QueryRow("SELECT filename, checksum FROM goose_db_version WHERE version_id = 1").Scan(&filename, &checksum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filename != "001_one.sql" || len(checksum) != 64 {
+		t.Errorf("unexpected record: filename %q checksum %q", filename, checksum)
+	}
+}
@@ -1,6 +1,8 @@
 package goosedb
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -121,3 +123,26 @@ func TestDriverSetFromEnvironmentVariable(t *testing.T) {
 			"got %v want %v", gotOpenString, wantOpenString)
 	}
 }
+
+func TestTemplateConfig(t *testing.T) {
+	dbconf, err := NewDBConf("../../db-sample", "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbconf.TemplatesDir != "../../db-sample/templates" || dbconf.Template != "" {
+		t.Errorf("default templates: got %q %q", dbconf.TemplatesDir, dbconf.Template)
+	}
+
+	dir := t.TempDir()
+	yml := "development:\n    driver: sqlite3\n    open: db.db\n    templatesdir: sql/templates\n    template: table\n"
+	if err := os.WriteFile(filepath.Join(dir, "dbconf.yml"), []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dbconf, err = NewDBConf(dir, "development", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbconf.TemplatesDir != filepath.Join(dir, "sql/templates") || dbconf.Template != "table" {
+		t.Errorf("configured templates: got %q %q", dbconf.TemplatesDir, dbconf.Template)
+	}
+}
@@ -1,31 +1,145 @@
 package goosedb
 
 import (
+	"context"
+	"crypto/sha1"
 	"database/sql"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/mattn/go-sqlite3"
 )
 
 // SqlDialect abstracts the details of specific SQL dialects
-// for goose's few SQL specific statements
+// for goose's few SQL specific statements. Implement it, and register the
+// implementation with RegisterDialect, to use goose with a database it does
+// not ship support for.
+//
+// Every method that refers to the version table is passed its name, already
+// quoted and qualified with its schema if one is configured.
 type SqlDialect interface {
-	createVersionTableSql() string // sql string to create the goose_db_version table
-	insertVersionSql() string      // sql string to insert the initial version table row
-	dbVersionQuery(db *sql.DB) (*sql.Rows, error)
+	// CreateVersionTableSql creates the version table with columns id, an
+	// auto-incrementing primary key; version_id, a bigint; is_applied, a
+	// boolean; and tstamp, a timestamp defaulting to the current time.
+	// goose adds any newer columns itself.
+	CreateVersionTableSql(table string) string
+
+	// InsertVersionSql inserts a version table row, taking a value for each
+	// of columns, in that order, as arguments. The columns are version_id
+	// and is_applied followed by the ones goose has added since, so a new
+	// column doesn't change this method.
+	InsertVersionSql(table string, columns []string) string
+
+	// UpdateChecksumSql sets the filename and checksum of the version
+	// table row with the given id, taking them as arguments in that order.
+	UpdateChecksumSql(table string) string
+
+	// DBVersionQuery returns the version_id and is_applied of every row of
+	// the version table, newest first. It returns ErrTableDoesNotExist if
+	// the table has not been created yet.
+	DBVersionQuery(ctx context.Context, db *sql.DB, table string) (*sql.Rows, error)
 }
 
-// drivers that we don't know about can ask for a dialect by name
-func dialectByName(d string) SqlDialect {
-	switch d {
-	case "postgres":
-		return &PostgresDialect{}
-	case "mysql":
-		return &MySqlDialect{}
-	case "sqlite3":
-		return &Sqlite3Dialect{}
+// LockingDialect is implemented by dialects that support DBConf.Lock.
+type LockingDialect interface {
+	SqlDialect
+
+	// TryLock makes one attempt to take the migration lock called name on
+	// conn, reporting whether it succeeded. Unlock releases it.
+	//
+	// name is the quoted name of a table named after the version table,
+	// <versiontable>_lock, qualified with its schema if one is configured.
+	// A dialect without session locks can keep the lock in that table;
+	// others only need it to tell locks apart.
+	TryLock(ctx context.Context, conn *sql.Conn, name string) (bool, error)
+	Unlock(ctx context.Context, conn *sql.Conn, name string) error
+}
+
+// LockClearer is implemented by locking dialects whose lock outlives a run
+// that is killed while holding it, so that ClearLock can release it.
+type LockClearer interface {
+	LockingDialect
+
+	// ClearLock releases the migration lock called name, whoever holds
+	// it, reporting whether it was held.
+	ClearLock(ctx context.Context, db *sql.DB, name string) (bool, error)
+}
+
+// ProgressDialect is implemented by dialects that support migrations
+// annotated with NO TRANSACTION, whose progress goose records in a table
+// next to the version table after each statement.
+type ProgressDialect interface {
+	SqlDialect
+
+	// CreateProgressTableSql creates the progress table, if it doesn't
+	// exist, with columns version_id, a bigint; is_applied, a boolean;
+	// statements_done, an int; and checksum, a varchar(64).
+	CreateProgressTableSql(table string) string
+
+	// SelectProgressSql returns the statements_done and checksum of the
+	// progress row with the given version_id and is_applied, taking them
+	// as arguments in that order.
+	SelectProgressSql(table string) string
+
+	// InsertProgressSql inserts a progress row, taking version_id,
+	// is_applied, statements_done and checksum as arguments.
+	InsertProgressSql(table string) string
+
+	// DeleteProgressSql deletes the progress row with the given version_id
+	// and is_applied, taking them as arguments in that order.
+	DeleteProgressSql(table string) string
+}
+
+// IdentifierQuoter is implemented by dialects that quote identifiers, like
+// the version table's name, with something other than the SQL standard's
+// double quotes.
+type IdentifierQuoter interface {
+	QuoteIdentifier(name string) string
+}
+
+// quoteIdentifier quotes name for d.
+func quoteIdentifier(d SqlDialect, name string) string {
+	if q, ok := d.(IdentifierQuoter); ok {
+		return q.QuoteIdentifier(name)
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// lockKey returns the integer key of the lock called name, for databases
+// whose locks are identified by number: the first eight bytes of the SHA-1
+// of name.
+func lockKey(name string) int64 {
+	sum := sha1.Sum([]byte(name))
+	return int64(binary.BigEndian.Uint64(sum[:8]))
+}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]SqlDialect{
+		"postgres": &PostgresDialect{},
+		"mysql":    &MySqlDialect{},
+		"sqlite3":  &Sqlite3Dialect{},
 	}
+)
+
+// RegisterDialect makes a dialect available by name, for the dialect key in
+// dbconf.yml and for drivers with the same name. Registering a name again
+// replaces the earlier dialect, including the built-in postgres, mysql and
+// sqlite3 dialects.
+func RegisterDialect(name string, d SqlDialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[name] = d
+}
 
-	return nil
+// drivers that we don't know about can ask for a dialect by name
+func dialectByName(d string) SqlDialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	return dialects[d]
 }
 
 ////////////////////////////
@@ -34,8 +148,8 @@ func dialectByName(d string) SqlDialect {
 
 type PostgresDialect struct{}
 
-func (pg PostgresDialect) createVersionTableSql() string {
-	return `CREATE TABLE goose_db_version (
+func (pg PostgresDialect) CreateVersionTableSql(table string) string {
+	return `CREATE TABLE ` + table + ` (
                 id serial NOT NULL,
                 version_id bigint NOT NULL,
                 is_applied boolean NOT NULL,
@@ -44,17 +158,40 @@ func (pg PostgresDialect) createVersionTableSql() string {
             );`
 }
 
-func (pg PostgresDialect) insertVersionSql() string {
-	return "INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, $2);"
+func (pg PostgresDialect) InsertVersionSql(table string, columns []string) string {
+	return insertSql(table, columns, func(n int) string { return "$" + strconv.Itoa(n) })
 }
 
-func (pg PostgresDialect) dbVersionQuery(db *sql.DB) (*sql.Rows, error) {
-	rows, err := db.Query("SELECT version_id, is_applied from goose_db_version ORDER BY id DESC")
+func (pg PostgresDialect) UpdateChecksumSql(table string) string {
+	return "UPDATE " + table + " SET filename = $1, checksum = $2 WHERE id = $3;"
+}
+
+func (pg PostgresDialect) CreateProgressTableSql(table string) string {
+	return createProgressTableSql(table)
+}
+
+func (pg PostgresDialect) SelectProgressSql(table string) string {
+	return "SELECT statements_done, checksum FROM " + table + " WHERE version_id = $1 AND is_applied = $2;"
+}
+
+func (pg PostgresDialect) InsertProgressSql(table string) string {
+	return "INSERT INTO " + table + " (version_id, is_applied, statements_done, checksum) VALUES ($1, $2, $3, $4);"
+}
+
+func (pg PostgresDialect) DeleteProgressSql(table string) string {
+	return "DELETE FROM " + table + " WHERE version_id = $1 AND is_applied = $2;"
+}
+
+func (pg PostgresDialect) DBVersionQuery(ctx context.Context, db *sql.DB, table string) (*sql.Rows, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version_id, is_applied from "+table+" ORDER BY id DESC")
 
 	// XXX: check for postgres specific error indicating the table doesn't exist.
 	// for now, assume any error is because the table doesn't exist,
 	// in which case we'll try to create it.
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		return nil, ErrTableDoesNotExist
 	}
 
@@ -68,14 +205,25 @@ func (pg PostgresDialect) dbVersionQuery(db *sql.DB) (*sql.Rows, error) {
 	return rows, err
 }
 
+func (pg PostgresDialect) TryLock(ctx context.Context, conn *sql.Conn, name string) (bool, error) {
+	var ok bool
+	err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockKey(name)).Scan(&ok)
+	return ok, err
+}
+
+func (pg PostgresDialect) Unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey(name))
+	return err
+}
+
 ////////////////////////////
 // MySQL
 ////////////////////////////
 
 type MySqlDialect struct{}
 
-func (m MySqlDialect) createVersionTableSql() string {
-	return `CREATE TABLE goose_db_version (
+func (m MySqlDialect) CreateVersionTableSql(table string) string {
+	return `CREATE TABLE ` + table + ` (
                 id serial NOT NULL,
                 version_id bigint NOT NULL,
                 is_applied boolean NOT NULL,
@@ -84,31 +232,73 @@ func (m MySqlDialect) createVersionTableSql() string {
             );`
 }
 
-func (m MySqlDialect) insertVersionSql() string {
-	return "INSERT INTO goose_db_version (version_id, is_applied) VALUES (?, ?);"
+func (m MySqlDialect) InsertVersionSql(table string, columns []string) string {
+	return insertSql(table, columns, func(int) string { return "?" })
 }
 
-func (m MySqlDialect) dbVersionQuery(db *sql.DB) (*sql.Rows, error) {
-	rows, err := db.Query("SELECT version_id, is_applied from goose_db_version ORDER BY id DESC")
+func (m MySqlDialect) UpdateChecksumSql(table string) string {
+	return "UPDATE " + table + " SET filename = ?, checksum = ? WHERE id = ?;"
+}
+
+func (m MySqlDialect) CreateProgressTableSql(table string) string {
+	return createProgressTableSql(table)
+}
+
+func (m MySqlDialect) SelectProgressSql(table string) string {
+	return "SELECT statements_done, checksum FROM " + table + " WHERE version_id = ? AND is_applied = ?;"
+}
+
+func (m MySqlDialect) InsertProgressSql(table string) string {
+	return "INSERT INTO " + table + " (version_id, is_applied, statements_done, checksum) VALUES (?, ?, ?, ?);"
+}
+
+func (m MySqlDialect) DeleteProgressSql(table string) string {
+	return "DELETE FROM " + table + " WHERE version_id = ? AND is_applied = ?;"
+}
+
+func (m MySqlDialect) DBVersionQuery(ctx context.Context, db *sql.DB, table string) (*sql.Rows, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version_id, is_applied from "+table+" ORDER BY id DESC")
 
 	// XXX: check for mysql specific error indicating the table doesn't exist.
 	// for now, assume any error is because the table doesn't exist,
 	// in which case we'll try to create it.
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		return nil, ErrTableDoesNotExist
 	}
 
 	return rows, err
 }
 
+func (m MySqlDialect) TryLock(ctx context.Context, conn *sql.Conn, name string) (bool, error) {
+	// GET_LOCK returns 1 if the lock was taken, 0 if another session holds
+	// it and NULL on error.
+	var ok sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&ok); err != nil {
+		return false, err
+	}
+	return ok.Valid && ok.Int64 == 1, nil
+}
+
+func (m MySqlDialect) Unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", name)
+	return err
+}
+
+func (m MySqlDialect) QuoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
 ////////////////////////////
 // sqlite3
 ////////////////////////////
 
 type Sqlite3Dialect struct{}
 
-func (m Sqlite3Dialect) createVersionTableSql() string {
-	return `CREATE TABLE goose_db_version (
+func (m Sqlite3Dialect) CreateVersionTableSql(table string) string {
+	return `CREATE TABLE ` + table + ` (
                 id INTEGER PRIMARY KEY AUTOINCREMENT,
                 version_id INTEGER NOT NULL,
                 is_applied INTEGER NOT NULL,
@@ -116,12 +306,32 @@ func (m Sqlite3Dialect) createVersionTableSql() string {
             );`
 }
 
-func (m Sqlite3Dialect) insertVersionSql() string {
-	return "INSERT INTO goose_db_version (version_id, is_applied) VALUES (?, ?);"
+func (m Sqlite3Dialect) InsertVersionSql(table string, columns []string) string {
+	return insertSql(table, columns, func(int) string { return "?" })
+}
+
+func (m Sqlite3Dialect) UpdateChecksumSql(table string) string {
+	return "UPDATE " + table + " SET filename = ?, checksum = ? WHERE id = ?;"
+}
+
+func (m Sqlite3Dialect) CreateProgressTableSql(table string) string {
+	return createProgressTableSql(table)
 }
 
-func (m Sqlite3Dialect) dbVersionQuery(db *sql.DB) (*sql.Rows, error) {
-	rows, err := db.Query("SELECT version_id, is_applied from goose_db_version ORDER BY id DESC")
+func (m Sqlite3Dialect) SelectProgressSql(table string) string {
+	return "SELECT statements_done, checksum FROM " + table + " WHERE version_id = ? AND is_applied = ?;"
+}
+
+func (m Sqlite3Dialect) InsertProgressSql(table string) string {
+	return "INSERT INTO " + table + " (version_id, is_applied, statements_done, checksum) VALUES (?, ?, ?, ?);"
+}
+
+func (m Sqlite3Dialect) DeleteProgressSql(table string) string {
+	return "DELETE FROM " + table + " WHERE version_id = ? AND is_applied = ?;"
+}
+
+func (m Sqlite3Dialect) DBVersionQuery(ctx context.Context, db *sql.DB, table string) (*sql.Rows, error) {
+	rows, err := db.QueryContext(ctx, "SELECT version_id, is_applied from "+table+" ORDER BY id DESC")
 
 	switch err.(type) {
 	case sqlite3.Error:
@@ -129,3 +339,61 @@ func (m Sqlite3Dialect) dbVersionQuery(db *sql.DB) (*sql.Rows, error) {
 	}
 	return rows, err
 }
+
+// sqlite has no session locks, so the lock is the row of the lock table,
+// which a killed run leaves behind until ClearLock removes it.
+func (m Sqlite3Dialect) TryLock(ctx context.Context, conn *sql.Conn, name string) (bool, error) {
+	if _, err := conn.ExecContext(ctx, createSqliteLockTableSql(name)); err != nil {
+		return false, sqliteBusy(err)
+	}
+	res, err := conn.ExecContext(ctx, "INSERT OR IGNORE INTO "+name+" (id) VALUES (1);")
+	if err != nil {
+		return false, sqliteBusy(err)
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (m Sqlite3Dialect) Unlock(ctx context.Context, conn *sql.Conn, name string) error {
+	_, err := conn.ExecContext(ctx, "DELETE FROM "+name+";")
+	return err
+}
+
+func (m Sqlite3Dialect) ClearLock(ctx context.Context, db *sql.DB, name string) (bool, error) {
+	if _, err := db.ExecContext(ctx, createSqliteLockTableSql(name)); err != nil {
+		return false, err
+	}
+	res, err := db.ExecContext(ctx, "DELETE FROM "+name+";")
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func createSqliteLockTableSql(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+                id INTEGER PRIMARY KEY,
+                locked_at TIMESTAMP DEFAULT (datetime('now'))
+            );`
+}
+
+// insertSql returns an INSERT of one row into table, with placeholder(n)
+// standing for the value of the nth column, counting from 1.
+func insertSql(table string, columns []string, placeholder func(n int) string) string {
+	values := make([]string, len(columns))
+	for i := range columns {
+		values[i] = placeholder(i + 1)
+	}
+	return "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(values, ", ") + ");"
+}
+
+// sqliteBusy returns nil if err means another connection is writing to the
+// database, so the lock attempt can be retried.
+func sqliteBusy(err error) error {
+	var serr sqlite3.Error
+	if errors.As(err, &serr) && (serr.Code == sqlite3.ErrBusy || serr.Code == sqlite3.ErrLocked) {
+		return nil
+	}
+	return err
+}
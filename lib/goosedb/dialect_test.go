@@ -0,0 +1,142 @@
+package goosedb
+
+import (
+	"database/sql"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// plainDialect has only the methods of SqlDialect, like a dialect written
+// outside this package that doesn't support locking.
+type plainDialect struct {
+	SqlDialect
+}
+
+func TestRegisterDialect(t *testing.T) {
+	RegisterDialect("plain-sqlite3", plainDialect{&Sqlite3Dialect{}})
+
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "migrations"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	yml := "test:\n  driver: sqlite3\n  open: " + filepath.Join(dir, "test.db") + "\n  dialect: plain-sqlite3\n" +
+		"unknown:\n  driver: sqlite3\n  open: x\n  dialect: nosuchdialect\n"
+	if err := os.WriteFile(filepath.Join(dir, "dbconf.yml"), []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeFile := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, "migrations", name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("001_one.sql", tableMigration("one"))
+
+	conf, err := NewDBConf(dir, "test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := conf.Driver.Dialect.(plainDialect); !ok {
+		t.Fatalf("expected the registered dialect, got %T", conf.Driver.Dialect)
+	}
+	conf.Logger = slog.New(slog.DiscardHandler)
+	if err := RunMigrations(conf, conf.MigrationsDir, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	conf.Lock = true
+	err = RunMigrations(conf, conf.MigrationsDir, 1)
+	if err == nil || !strings.Contains(err.Error(), "does not support the migration lock") {
+		t.Errorf("expected an error about locking, got %v", err)
+	}
+	conf.Lock = false
+
+	writeFile("002_notx.sql", "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE TABLE two (id int);\n")
+	err = RunMigrations(conf, conf.MigrationsDir, 2)
+	if err == nil || !strings.Contains(err.Error(), "does not support NO TRANSACTION") {
+		t.Errorf("expected an error about NO TRANSACTION, got %v", err)
+	}
+
+	if _, err := NewDBConf(dir, "unknown", ""); err == nil || !strings.Contains(err.Error(), "nosuchdialect") {
+		t.Errorf("expected an unknown dialect error, got %v", err)
+	}
+}
+
+func TestQualifiedVersionTable(t *testing.T) {
+	tests := []struct {
+		dialect SqlDialect
+		table   string
+		schema  string
+		want    string
+	}{
+		{&PostgresDialect{}, "", "", `"goose_db_version"`},
+		{&PostgresDialect{}, "app_versions", "billing", `"billing"."app_versions"`},
+		{&MySqlDialect{}, "app_versions", "billing", "`billing`.`app_versions`"},
+		{&Sqlite3Dialect{}, `odd"name`, "", `"odd""name"`},
+	}
+	for _, tt := range tests {
+		conf := &DBConf{Driver: DBDriver{Dialect: tt.dialect}, VersionTable: tt.table, VersionSchema: tt.schema}
+		if got := conf.versionTable(); got != tt.want {
+			t.Errorf("%T %q %q: got %s, want %s", tt.dialect, tt.schema, tt.table, got, tt.want)
+		}
+	}
+}
+
+func TestInsertVersionSql(t *testing.T) {
+	columns := []string{"version_id", "is_applied", "filename"}
+	tests := []struct {
+		dialect SqlDialect
+		want    string
+	}{
+		{&PostgresDialect{}, `INSERT INTO t (version_id, is_applied, filename) VALUES ($1, $2, $3);`},
+		{&MySqlDialect{}, `INSERT INTO t (version_id, is_applied, filename) VALUES (?, ?, ?);`},
+		{&Sqlite3Dialect{}, `INSERT INTO t (version_id, is_applied, filename) VALUES (?, ?, ?);`},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.InsertVersionSql("t", columns); got != tt.want {
+			t.Errorf("%T: got %s, want %s", tt.dialect, got, tt.want)
+		}
+	}
+}
+
+func TestLockKey(t *testing.T) {
+	// the key of the default lock must not change, so that runs of
+	// different goose versions exclude each other
+	if got := lockKey("goose_db_version"); got != 0x581fb93759e577b9 {
+		t.Errorf("lockKey: got %#x", got)
+	}
+}
+
+func TestRegisterDialectForNewDriver(t *testing.T) {
+	// a driver goose doesn't know about, registered by the program
+	const name = "custom-sqlite3"
+	if !slices.Contains(sql.Drivers(), name) {
+		sql.Register(name, &sqlite3.SQLiteDriver{})
+	}
+	RegisterDialect(name, plainDialect{&Sqlite3Dialect{}})
+
+	dir := t.TempDir()
+	conf, err := NewConfig(name, filepath.Join(dir, "test.db"), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := conf.Driver.Dialect.(plainDialect); !ok {
+		t.Fatalf("expected the registered dialect, got %T", conf.Driver.Dialect)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "001_one.sql"), []byte(tableMigration("one")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf.Logger = slog.New(slog.DiscardHandler)
+	if err := RunMigrations(conf, conf.MigrationsDir, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewConfig("no-such-driver", "x", dir); err == nil {
+		t.Error("expected an error for a driver without a dialect")
+	}
+}
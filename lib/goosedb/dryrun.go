@@ -0,0 +1,188 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kevinburke/goose/lib/goose"
+)
+
+// dryRunMigrations writes the SQL that running ms would execute to
+// conf.DryRun, without executing any of it.
+func dryRunMigrations(ctx context.Context, conf *DBConf, db *sql.DB, current, target int64, ms migrationSorter) error {
+	w := conf.DryRun
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- goose: dry run of db environment '%v', current version: %d, target: %d\n",
+		conf.Env, current, target)
+	setup, err := versionTableSetup(ctx, conf, db)
+	if err != nil {
+		return err
+	}
+	b.WriteString(setup)
+	if len(ms) == 0 {
+		fmt.Fprintf(&b, "-- goose: no migrations to run. current version: %d\n", current)
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+
+	direction := target >= current
+	for _, m := range ms {
+		if err := WriteMigrationSQL(w, conf, m, direction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// versionTableSetup returns the annotated SQL a run would execute to create
+// or upgrade the version table, or "" if the table is up to date.
+func versionTableSetup(ctx context.Context, conf *DBConf, db *sql.DB) (string, error) {
+	d := conf.Driver.Dialect
+	table := conf.versionTable()
+
+	rows, err := d.DBVersionQuery(ctx, db, table)
+	if err == nil {
+		rows.Close()
+	}
+
+	var b strings.Builder
+	switch {
+	case err == ErrTableDoesNotExist:
+		fmt.Fprintf(&b, "\n-- %s does not exist and would be created\n", conf.versionTableName())
+		b.WriteString(d.CreateVersionTableSql(table) + "\n")
+		for _, c := range versionColumns {
+			b.WriteString(addVersionColumnSql(table, c) + ";\n")
+		}
+		b.WriteString(conf.insertVersionSql() + "\n")
+		b.WriteString(sqlArgsComment(dryRunArgs(conf, versionRecord{version: 0, applied: true})...))
+	case err != nil:
+		return "", err
+	default:
+		for _, c := range versionColumns {
+			ok, err := hasColumns(ctx, db, table, c.name)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				continue
+			}
+			if b.Len() == 0 {
+				fmt.Fprintf(&b, "\n-- %s would be upgraded\n", conf.versionTableName())
+			}
+			b.WriteString(addVersionColumnSql(table, c) + ";\n")
+		}
+	}
+	return b.String(), nil
+}
+
+// WriteMigrationSQL writes the SQL that running m in the given direction
+// would execute to w: each statement with its line in the migration file,
+// whether the migration runs in a transaction, and the version table insert
+// that records it. Nothing is executed.
+//
+// Go migrations are listed with the insert only, since the SQL they run is
+// not known until they run.
+func WriteMigrationSQL(w io.Writer, conf *DBConf, m *goose.Migration, direction bool) error {
+	if m.Go != nil {
+		return writeGoMigration(w, conf, m, direction)
+	}
+	if filepath.Ext(m.Source) != ".sql" {
+		return nil
+	}
+	sm, err := prepareSQLMigration(conf, m.Source, m.Version, direction)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	inTxn := !sm.outsideTxn && !sm.noTransaction
+	how := "in a transaction"
+	switch {
+	case sm.noTransaction:
+		how = "NO TRANSACTION, each statement on its own, recording progress in " + conf.versionTableName() + "_progress after each"
+	case sm.outsideTxn:
+		how = "outside a transaction, because its statement cannot run in one"
+	}
+	fmt.Fprintf(&b, "\n-- %s: version %d, %s, %s\n", sm.filename, sm.version, directionName(direction), how)
+	if inTxn {
+		b.WriteString("BEGIN;\n")
+	}
+	for i, stmt := range sm.statements {
+		fmt.Fprintf(&b, "-- statement %d, line %d\n", i+1, stmt.line)
+		b.WriteString(strings.TrimSpace(stmt.sql) + "\n")
+	}
+	b.WriteString("-- record the version\n")
+	b.WriteString(conf.insertVersionSql() + "\n")
+	record := versionRecord{version: sm.version, applied: direction, filename: sm.filename, checksum: sm.checksum, start: time.Now()}
+	b.WriteString(sqlArgsComment(dryRunArgs(conf, record)...))
+	if inTxn {
+		b.WriteString("COMMIT;\n")
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// writeGoMigration is WriteMigrationSQL for a Go migration.
+func writeGoMigration(w io.Writer, conf *DBConf, m *goose.Migration, direction bool) error {
+	var b strings.Builder
+	filename := filepath.Base(m.Source)
+	how := "in a transaction"
+	if m.Go.NoTx {
+		how = "outside a transaction"
+	}
+	fmt.Fprintf(&b, "\n-- %s: version %d, %s, Go function %s\n", filename, m.Version, directionName(direction), how)
+	if !m.Go.NoTx {
+		b.WriteString("BEGIN;\n")
+	}
+	b.WriteString("-- (Go function)\n")
+	b.WriteString("-- record the version\n")
+	b.WriteString(conf.insertVersionSql() + "\n")
+	record := versionRecord{version: m.Version, applied: direction, filename: filename, start: time.Now()}
+	b.WriteString(sqlArgsComment(dryRunArgs(conf, record)...))
+	if !m.Go.NoTx {
+		b.WriteString("COMMIT;\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// placeholder is an argument that is only known when the statement runs,
+// written as is by sqlArgsComment.
+type placeholder string
+
+// dryRunArgs returns the arguments of the version table insert for r, with
+// placeholders for the migration's duration and the time it is recorded.
+func dryRunArgs(conf *DBConf, r versionRecord) []any {
+	args := r.args(conf)
+	if !r.start.IsZero() {
+		args[5] = placeholder("<duration_ms>")
+	}
+	args[9] = placeholder("<now>")
+	return args
+}
+
+// sqlArgsComment formats the arguments bound to a statement as a SQL comment.
+func sqlArgsComment(args ...any) string {
+	vals := make([]string, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case nil:
+			vals[i] = "NULL"
+		case placeholder:
+			vals[i] = string(arg)
+		case string:
+			vals[i] = "'" + strings.ReplaceAll(arg, "'", "''") + "'"
+		default:
+			vals[i] = fmt.Sprint(arg)
+		}
+	}
+	return "-- args: " + strings.Join(vals, ", ") + "\n"
+}
@@ -0,0 +1,72 @@
+package goosedb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"002_two.sql": "-- +goose Up\nCREATE TABLE two (id int);\nINSERT INTO two VALUES (1);\n\n-- +goose Down\nDROP TABLE two;\n",
+	})
+	var out strings.Builder
+	conf.DryRun = &out
+
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	for _, want := range []string{
+		"current version: 0, target: 2",
+		"goose_db_version does not exist and would be created",
+		"-- 001_one.sql: version 1, up, in a transaction\nBEGIN;\n-- statement 1, line 2\n-- +goose Up\nCREATE TABLE one (id int);\n",
+		"-- statement 2, line 3\nINSERT INTO two VALUES (1);\n",
+		"-- args: 2, true, '002_two.sql', '",
+		"COMMIT;\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("dry run output missing %q:\n%s", want, got)
+		}
+	}
+
+	// nothing ran, not even creating the version table
+	if _, err := db.Exec("SELECT 1 FROM goose_db_version"); err == nil {
+		t.Error("dry run created the version table")
+	}
+
+	conf.DryRun = nil
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	conf.DryRun = &out
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db); err != nil {
+		t.Fatal(err)
+	}
+	got = out.String()
+	if strings.Contains(got, "001_one.sql") || !strings.Contains(got, "002_two.sql") {
+		t.Errorf("expected only 002_two.sql to be planned:\n%s", got)
+	}
+	if strings.Contains(got, "goose_db_version") && strings.Contains(got, "would be") {
+		t.Errorf("expected no version table setup:\n%s", got)
+	}
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 1 {
+		t.Errorf("EnsureDBVersion: got %d, %v, want 1", v, err)
+	}
+}
+
+func TestDryRunOutsideTransaction(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_idx.sql": "-- +goose Up\nCREATE INDEX CONCURRENTLY idx ON t (id);\n\n-- +goose Down\nDROP INDEX idx;\n",
+	})
+	var out strings.Builder
+	conf.DryRun = &out
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+	got := out.String()
+	if !strings.Contains(got, "outside a transaction") || strings.Contains(got, "BEGIN;") {
+		t.Errorf("expected the migration to run outside a transaction:\n%s", got)
+	}
+}
@@ -0,0 +1,313 @@
+package goosedb
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kylelemons/go-gypsy/yaml"
+)
+
+// ErrNoTargets is returned by Targets when conf.FanOut lists no targets.
+var ErrNoTargets = errors.New("goosedb: no fan-out targets configured")
+
+// FanOut configures migrating many databases, or many Postgres schemas in
+// one database, that share the same migrations. Targets may come from any
+// combination of Schemas, SchemaQuery and DSNFile.
+type FanOut struct {
+	// Schemas are Postgres schemas in the configured database.
+	Schemas []string
+
+	// SchemaQuery is run on the configured database and returns one
+	// Postgres schema name per row.
+	SchemaQuery string
+
+	// DSNFile is a file with one connection string per line for the
+	// configured driver. Blank lines and lines starting with # are
+	// ignored.
+	DSNFile string
+
+	// Concurrency is how many targets are migrated at once, 1 if zero.
+	Concurrency int
+
+	// ContinueOnError keeps migrating the remaining targets after one
+	// fails. Otherwise no new target is started after a failure.
+	ContinueOnError bool
+}
+
+// Target is one database or schema of a fan-out run.
+type Target struct {
+	// Name identifies the target in reports: the schema name, or the
+	// connection string with any password removed.
+	Name string
+	Conf *DBConf
+}
+
+// TargetResult reports the outcome of migrating one target.
+type TargetResult struct {
+	Target  string
+	Before  int64 // the version before the run, or -1 if it is unknown
+	After   int64 // the version after the run, or -1 if it is unknown
+	Skipped bool  // not started because an earlier target failed
+	Err     error
+
+	Duration time.Duration
+}
+
+// Targets returns a DBConf for every target in conf.FanOut: a copy of conf
+// with PgSchema set to each schema, which then holds that schema's version
+// table, then a copy with the connection string replaced by each DSN. Each
+// schema's DB sets the search path on every connection it opens.
+func Targets(conf *DBConf) ([]Target, error) {
+	return TargetsContext(context.Background(), conf)
+}
+
+// TargetsContext is like Targets but uses ctx for SchemaQuery.
+func TargetsContext(ctx context.Context, conf *DBConf) ([]Target, error) {
+	fo := conf.FanOut
+	schemas := append([]string(nil), fo.Schemas...)
+	if fo.SchemaQuery != "" {
+		queried, err := querySchemas(ctx, conf, fo.SchemaQuery)
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, queried...)
+	}
+	if len(schemas) > 0 && conf.Driver.Name != "postgres" {
+		return nil, fmt.Errorf("goosedb: fan-out schemas need the postgres driver, not %q", conf.Driver.Name)
+	}
+
+	var targets []Target
+	for _, schema := range schemas {
+		c := *conf
+		c.PgSchema = schema
+		// each schema keeps its history in a version table of its own
+		c.VersionSchema = ""
+		targets = append(targets, Target{Name: schema, Conf: &c})
+	}
+
+	if fo.DSNFile != "" {
+		dsns, err := readDSNFile(fo.DSNFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, dsn := range dsns {
+			c := *conf
+			c.Driver.OpenStr = dsn
+			targets = append(targets, Target{Name: redactDSN(dsn), Conf: &c})
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, ErrNoTargets
+	}
+	return targets, nil
+}
+
+func querySchemas(ctx context.Context, conf *DBConf, query string) ([]string, error) {
+	db, err := OpenDBFromDBConfContext(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("goosedb: fan-out schema query: %w", err)
+	}
+	defer rows.Close()
+	var schemas []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, fmt.Errorf("goosedb: fan-out schema query: %w", err)
+		}
+		schemas = append(schemas, s)
+	}
+	return schemas, rows.Err()
+}
+
+func readDSNFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var dsns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dsns = append(dsns, os.ExpandEnv(line))
+	}
+	return dsns, scanner.Err()
+}
+
+var (
+	// password=secret in a key/value connection string
+	kvPassword = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+	// user:secret@ in a MySQL connection string
+	mysqlPassword = regexp.MustCompile(`^([^:@/]*):[^@]*@`)
+)
+
+// redactDSN returns dsn with any password replaced by xxxxx.
+func redactDSN(dsn string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Redacted()
+	}
+	dsn = kvPassword.ReplaceAllString(dsn, "${1}xxxxx")
+	return mysqlPassword.ReplaceAllString(dsn, "${1}:xxxxx@")
+}
+
+// MigrateTargets migrates every target to version, as RunMigrations would
+// migrate each one's DBConf, running up to conf.FanOut.Concurrency of them
+// at once. Unless conf.FanOut.ContinueOnError is set, no target is started
+// after one fails. It returns a result for every target, in order, and an
+// error if any target failed.
+func MigrateTargets(conf *DBConf, targets []Target, version int64) ([]TargetResult, error) {
+	return MigrateTargetsContext(context.Background(), conf, targets, version)
+}
+
+// MigrateTargetsContext is like MigrateTargets but uses ctx for every query.
+func MigrateTargetsContext(ctx context.Context, conf *DBConf, targets []Target, version int64) ([]TargetResult, error) {
+	concurrency := conf.FanOut.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]TargetResult, len(targets))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	failed := false
+	for i, t := range targets {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := failed && !conf.FanOut.ContinueOnError
+		mu.Unlock()
+		if stop || ctx.Err() != nil {
+			<-sem
+			results[i] = TargetResult{Target: t.Name, Before: -1, After: -1, Skipped: true}
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, t Target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = migrateTarget(ctx, t, version)
+			if results[i].Err != nil {
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Target, r.Err))
+		}
+	}
+	if len(errs) > 0 {
+		return results, fmt.Errorf("goosedb: %d of %d fan-out target(s) failed: %w", len(errs), len(targets), errors.Join(errs...))
+	}
+	return results, nil
+}
+
+func migrateTarget(ctx context.Context, t Target, version int64) (res TargetResult) {
+	res = TargetResult{Target: t.Name, Before: -1, After: -1}
+	start := time.Now()
+	defer func() { res.Duration = time.Since(start) }()
+
+	conf := *t.Conf
+	conf.Logger = targetLogger(t.Conf, t.Name)
+
+	db, err := OpenDBFromDBConfContext(ctx, &conf)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer db.Close()
+
+	// EnsureDBVersion creates the version table, which dry runs must not do
+	if conf.DryRun == nil {
+		if res.Before, err = EnsureDBVersionContext(ctx, &conf, db); err != nil {
+			res.Before, res.Err = -1, err
+			return res
+		}
+	}
+	res.Err = RunMigrationsOnDbContext(ctx, &conf, conf.MigrationsDir, version, db)
+	if conf.DryRun == nil {
+		if after, err := EnsureDBVersionContext(ctx, &conf, db); err == nil {
+			res.After = after
+		}
+	}
+	return res
+}
+
+// targetLogger returns the logger for one target of a fan-out run. The
+// default output is prefixed with the target's name, since the output of
+// concurrent targets interleaves.
+func targetLogger(conf *DBConf, name string) *slog.Logger {
+	if conf.Logger != nil {
+		return conf.Logger.With("target", name)
+	}
+	return slog.New(&messageHandler{w: os.Stdout, prefix: "[" + name + "] "})
+}
+
+// readFanOut returns the fan-out configured for env in dbconf.yml, such as:
+//
+//	fanout:
+//	    schemas:
+//	        - tenant_a
+//	        - tenant_b
+//	    schemaquery: SELECT nspname FROM pg_namespace WHERE nspname LIKE 'tenant_%'
+//	    dsnfile: shards.txt
+//	    concurrency: 4
+//	    continueonerror: true
+//
+// The DSN file is relative to dir, the directory holding dbconf.yml.
+func readFanOut(f *yaml.File, env, dir string) (FanOut, error) {
+	var fo FanOut
+	spec := env + ".fanout"
+	if n, err := f.Count(spec + ".schemas"); err == nil {
+		for i := 0; i < n; i++ {
+			schema, err := f.Get(fmt.Sprintf("%s.schemas[%d]", spec, i))
+			if err != nil {
+				return fo, fmt.Errorf("goose: %s.schemas in dbconf.yml: %w", spec, err)
+			}
+			fo.Schemas = append(fo.Schemas, schema)
+		}
+	}
+	if q, err := f.Get(spec + ".schemaquery"); err == nil {
+		fo.SchemaQuery = q
+	}
+	if path, err := f.Get(spec + ".dsnfile"); err == nil {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		fo.DSNFile = path
+	}
+	if n, err := f.GetInt(spec + ".concurrency"); err == nil {
+		fo.Concurrency = int(n)
+	}
+	if b, err := f.GetBool(spec + ".continueonerror"); err == nil {
+		fo.ContinueOnError = b
+	}
+	return fo, nil
+}
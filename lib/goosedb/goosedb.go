@@ -1,11 +1,17 @@
 package goosedb
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/kevinburke/goose/lib/goose"
 	"github.com/kylelemons/go-gypsy/yaml"
 )
 
@@ -22,7 +28,89 @@ type DBConf struct {
 	MigrationsDir string
 	Env           string
 	Driver        DBDriver
-	PgSchema      string
+
+	// PgSchema is the search path of every Postgres connection, a schema
+	// or a comma-separated list of them. The version table lives in the
+	// first, unless VersionSchema is set. CreatePgSchema creates the first
+	// schema when the database is opened if it doesn't exist.
+	PgSchema       string
+	CreatePgSchema bool
+
+	// MigrationsFS, if set, is the file system migrations are read from,
+	// such as an embed.FS, and MigrationsDir is a path within it.
+	// Otherwise migrations are read from disk.
+	MigrationsFS fs.FS
+
+	// StatementTimeout bounds how long any single migration statement may
+	// run. Zero means no limit beyond the context passed to the run.
+	StatementTimeout time.Duration
+
+	// Logger receives progress, timing and warning output. If nil, the
+	// message of each Info and Warn record is printed to stdout, which
+	// matches goose's traditional output. Use slog.New(slog.DiscardHandler)
+	// to silence it.
+	Logger *slog.Logger
+
+	// Lock serializes concurrent migration runs against the same database
+	// with an advisory lock: pg_advisory_lock on Postgres, GET_LOCK on
+	// MySQL and a <versiontable>_lock table on sqlite3. The lock is held
+	// on a connection of its own, so the *sql.DB must allow at least two
+	// open connections. See ClearLock for a sqlite3 run killed while
+	// holding it.
+	Lock bool
+
+	// LockTimeout bounds how long to wait for the migration lock before
+	// failing with ErrLockTimeout. Zero waits until the context is done.
+	LockTimeout time.Duration
+
+	// VerifyChecksums makes a run fail with a *ChecksumError before
+	// running anything if an applied migration's file has changed or
+	// disappeared since it was applied.
+	VerifyChecksums bool
+
+	// DryRun, if set, makes runs write the SQL they would execute to it,
+	// annotated with how each migration would run, instead of executing
+	// anything. The plan is still resolved against the version table, but
+	// the table is not created or upgraded.
+	DryRun io.Writer
+
+	// VersionTable is the name of the table that records applied
+	// migrations, goose_db_version if empty. VersionSchema, if set, is the
+	// schema that holds it. Give applications that share a database
+	// different version tables to keep their migration histories apart.
+	VersionTable  string
+	VersionSchema string
+
+	// AppliedBy is recorded in the version table as the user who applied
+	// each migration, such as a deploy's author. If empty, the OS user
+	// running goose is recorded.
+	AppliedBy string
+
+	// AllowMissing lets an up migration apply migrations that are older
+	// than the current version but were never applied, instead of failing.
+	AllowMissing bool
+
+	// TemplatesDir is the directory goose create reads migration templates
+	// from, and Template is the name of the one to use when none is
+	// given. See goose.LoadTemplate.
+	TemplatesDir string
+	Template     string
+
+	// Tracks are named migration histories kept apart from the default
+	// one, keyed by name. Track is the name of the track c runs, or "" for
+	// the default track; select one with ForTrack. Go migrations run only
+	// on the track they were registered for.
+	Tracks map[string]Track
+	Track  string
+
+	// FanOut lists the databases or schemas that Targets and
+	// MigrateTargets migrate together.
+	FanOut FanOut
+
+	// Hooks are run around each migration run and each migration in it.
+	// NewDBConf fills it from the hooks in dbconf.yml; append to it to add
+	// callbacks in Go.
+	Hooks []Hook
 }
 
 // NewConfig returns a DBConf for the given driver name, connection string, and
@@ -50,19 +138,19 @@ func NewConfigCustom(driver DBDriver, migrationsDir string) (*DBConf, error) {
 //
 // Callers must Close() the returned DB.
 func OpenDBFromDBConf(conf *DBConf) (*sql.DB, error) {
-	db, err := sql.Open(conf.Driver.Name, conf.Driver.OpenStr)
-	if err != nil {
-		return nil, err
-	}
+	return OpenDBFromDBConfContext(context.Background(), conf)
+}
 
-	// if a postgres schema has been specified, apply it
-	if conf.Driver.Name == "postgres" && conf.PgSchema != "" {
-		if _, err := db.Exec("SET search_path TO " + conf.PgSchema); err != nil {
-			return nil, err
-		}
+// OpenDBFromDBConfContext is like OpenDBFromDBConf but uses ctx for any
+// statements run to configure the new DB.
+//
+// If a Postgres schema is configured, it is set as the search path of every
+// connection the DB opens, not only the first.
+func OpenDBFromDBConfContext(ctx context.Context, conf *DBConf) (*sql.DB, error) {
+	if len(conf.pgSchemas()) > 0 {
+		return openPostgresSchema(ctx, conf)
 	}
-
-	return db, nil
+	return sql.Open(conf.Driver.Name, conf.Driver.OpenStr)
 }
 
 // extract configuration details from the given file
@@ -96,6 +184,9 @@ func NewDBConf(p, env string, pgschema string) (*DBConf, error) {
 	// allow the configuration to override the Dialect for this driver
 	if dialect, err := f.Get(fmt.Sprintf("%s.dialect", env)); err == nil {
 		d.Dialect = dialectByName(dialect)
+		if d.Dialect == nil {
+			return nil, fmt.Errorf("goose: unknown dialect %q; register it with goosedb.RegisterDialect", dialect)
+		}
 	}
 
 	conf, err := NewConfigCustom(d, filepath.Join(p, "migrations"))
@@ -104,6 +195,44 @@ func NewDBConf(p, env string, pgschema string) (*DBConf, error) {
 	}
 	conf.Env = env
 	conf.PgSchema = pgschema
+	if create, err := f.GetBool(fmt.Sprintf("%s.createpgschema", env)); err == nil {
+		conf.CreatePgSchema = create
+	}
+
+	// the version table may be moved or renamed
+	if table, err := f.Get(fmt.Sprintf("%s.versiontable", env)); err == nil {
+		conf.VersionTable = table
+	}
+	if schema, err := f.Get(fmt.Sprintf("%s.versionschema", env)); err == nil {
+		conf.VersionSchema = schema
+	}
+
+	// templates for goose create live next to the migrations by default
+	conf.TemplatesDir = filepath.Join(p, "templates")
+	if dir, err := f.Get(fmt.Sprintf("%s.templatesdir", env)); err == nil {
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(p, dir)
+		}
+		conf.TemplatesDir = dir
+	}
+	if name, err := f.Get(fmt.Sprintf("%s.template", env)); err == nil {
+		conf.Template = name
+	}
+
+	conf.Tracks, err = readTracks(f, env, p)
+	if err != nil {
+		return nil, err
+	}
+
+	conf.FanOut, err = readFanOut(f, env, p)
+	if err != nil {
+		return nil, err
+	}
+
+	conf.Hooks, err = readHooks(f, env, p)
+	if err != nil {
+		return nil, err
+	}
 	return conf, nil
 }
 
@@ -133,12 +262,45 @@ func newDBDriver(name, open string) DBDriver {
 	case "sqlite3":
 		d.Import = "github.com/kevinburke/goose/vendor/github.com/mattn/go-sqlite3"
 		d.Dialect = &Sqlite3Dialect{}
+
+	default:
+		// a registered dialect may share the driver's name
+		d.Dialect = dialectByName(name)
 	}
 
 	return d
 }
 
-// ensure we have enough info about this driver
+// collectMigrations collects the migrations in dir, from conf.MigrationsFS if
+// it is set or from disk otherwise.
+func (c *DBConf) collectMigrations(dir string, current, target int64) ([]*goose.Migration, error) {
+	if c.MigrationsFS != nil {
+		return goose.CollectTrackMigrationsFS(c.Track, c.MigrationsFS, dir, current, target)
+	}
+	return goose.CollectTrackMigrations(c.Track, dir, current, target)
+}
+
+// openMigration opens the migration file at source, a path returned by
+// collectMigrations.
+func (c *DBConf) openMigration(source string) (io.ReadCloser, error) {
+	if c.MigrationsFS != nil {
+		return c.MigrationsFS.Open(source)
+	}
+	return os.Open(source)
+}
+
+// readMigration returns the contents of the migration file at source.
+func (c *DBConf) readMigration(source string) ([]byte, error) {
+	f, err := c.openMigration(source)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// ensure we have enough info about this driver. Import is optional: drivers
+// registered outside goose have a dialect but no vendored import path.
 func (drv *DBDriver) IsValid() bool {
-	return len(drv.Import) > 0 && drv.Dialect != nil
+	return drv.Dialect != nil
 }
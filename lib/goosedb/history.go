@@ -0,0 +1,113 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// HistoryEntry is one row of the version table: a migration applied, rolled
+// back or baselined.
+type HistoryEntry struct {
+	Version   int64
+	Filename  string // empty for rows written before goose recorded it
+	Direction string // "up" or "down"
+	Baselined bool
+
+	// At is when the migration ran: in UTC, or in the database server's
+	// time zone for rows written by versions of goose that didn't record
+	// UTC.
+	At time.Time
+
+	// Duration, AppliedBy, Hostname and GooseVersion are zero for rows
+	// written before goose recorded them. Duration is also zero for
+	// baselined migrations.
+	Duration     time.Duration
+	AppliedBy    string
+	Hostname     string
+	GooseVersion string
+}
+
+// HistoryFilter restricts the entries returned by GetHistory. The zero
+// HistoryFilter returns every entry.
+type HistoryFilter struct {
+	// FromVersion and ToVersion bound the versions returned, inclusive.
+	// A zero ToVersion means no upper bound.
+	FromVersion int64
+	ToVersion   int64
+
+	// Since and Until bound when the entries ran, inclusive. The zero Time
+	// means no bound.
+	Since time.Time
+	Until time.Time
+}
+
+func (f HistoryFilter) match(e HistoryEntry) bool {
+	switch {
+	case e.Version < f.FromVersion:
+		return false
+	case f.ToVersion != 0 && e.Version > f.ToVersion:
+		return false
+	case !f.Since.IsZero() && e.At.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && e.At.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// GetHistory returns every migration recorded in the version table as
+// applied or rolled back, oldest first, that matches filter. It creates the
+// version table if it doesn't exist.
+func GetHistory(conf *DBConf, db *sql.DB, filter HistoryFilter) ([]HistoryEntry, error) {
+	return GetHistoryContext(context.Background(), conf, db, filter)
+}
+
+// GetHistoryContext is like GetHistory but uses ctx for every query.
+func GetHistoryContext(ctx context.Context, conf *DBConf, db *sql.DB, filter HistoryFilter) ([]HistoryEntry, error) {
+	// creates the version table, or adds the columns read below to one
+	// created by an older goose
+	if _, err := EnsureDBVersionContext(ctx, conf, db); err != nil {
+		return nil, err
+	}
+
+	// version 0 marks the creation of the version table, not a migration
+	q := fmt.Sprintf("SELECT version_id, is_applied, tstamp, filename, baselined, duration_ms, applied_by, hostname, goose_version, tstamp_utc FROM %s WHERE version_id > 0 ORDER BY id", conf.versionTable())
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		var e HistoryEntry
+		var applied bool
+		var filename, appliedBy, hostname, gooseVersion sql.NullString
+		var baselined sql.NullBool
+		var durationMS sql.NullInt64
+		var tstampUTC sql.NullTime
+		if err := rows.Scan(&e.Version, &applied, &e.At, &filename, &baselined,
+			&durationMS, &appliedBy, &hostname, &gooseVersion, &tstampUTC); err != nil {
+			return nil, err
+		}
+		e.Direction = "down"
+		if applied {
+			e.Direction = "up"
+		}
+		if tstampUTC.Valid {
+			e.At = tstampUTC.Time.UTC()
+		}
+		e.Filename = filename.String
+		e.Baselined = baselined.Bool
+		e.Duration = time.Duration(durationMS.Int64) * time.Millisecond
+		e.AppliedBy = appliedBy.String
+		e.Hostname = hostname.String
+		e.GooseVersion = gooseVersion.String
+		if filter.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	return entries, rows.Err()
+}
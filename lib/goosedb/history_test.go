@@ -0,0 +1,67 @@
+package goosedb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetHistory(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"002_two.sql": tableMigration("two"),
+	})
+
+	// apply both, roll back 2 and apply it again
+	for _, target := range []int64{2, 1, 2} {
+		if err := RunMigrationsOnDb(conf, conf.MigrationsDir, target, db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := GetHistory(conf, db, HistoryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		version   int64
+		direction string
+	}{{1, "up"}, {2, "up"}, {2, "down"}, {2, "up"}}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(entries), len(want), entries)
+	}
+	for i, w := range want {
+		e := entries[i]
+		if e.Version != w.version || e.Direction != w.direction {
+			t.Errorf("entry %d: got version %d %s, want %d %s", i, e.Version, e.Direction, w.version, w.direction)
+		}
+		if e.Filename == "" || e.AppliedBy == "" || e.At.Location() != time.UTC {
+			t.Errorf("entry %d: %+v", i, e)
+		}
+		if i > 0 && e.At.Before(entries[i-1].At) {
+			t.Errorf("entry %d is out of order", i)
+		}
+	}
+
+	entries, err = GetHistory(conf, db, HistoryFilter{FromVersion: 2, ToVersion: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Errorf("version 2: got %d entries, want 3", len(entries))
+	}
+
+	entries, err = GetHistory(conf, db, HistoryFilter{Until: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("an hour ago: got %d entries, want 0", len(entries))
+	}
+	entries, err = GetHistory(conf, db, HistoryFilter{Since: time.Now().Add(-time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Errorf("since an hour ago: got %d entries, want 4", len(entries))
+	}
+}
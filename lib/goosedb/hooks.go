@@ -0,0 +1,264 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kevinburke/goose/lib/goose"
+	"github.com/kylelemons/go-gypsy/yaml"
+)
+
+// RunEvent describes a migration run to the BeforeRun and AfterRun hooks.
+type RunEvent struct {
+	Env        string
+	Direction  string // "up" or "down"
+	Current    int64  // the version before the run
+	Target     int64
+	Migrations []*goose.Migration // the migrations the run applies, in order
+
+	// Duration and Err are set for AfterRun only. Err is the error that
+	// stopped the run, or nil if every migration succeeded.
+	Duration time.Duration
+	Err      error
+}
+
+// MigrationEvent describes a single migration to the BeforeMigration and
+// AfterMigration hooks.
+type MigrationEvent struct {
+	Env       string
+	Version   int64
+	Source    string // path to the migration file, or name of a Go migration
+	Direction string // "up" or "down"
+
+	// Duration and Err are set for AfterMigration only.
+	Duration time.Duration
+	Err      error
+}
+
+// Hook holds callbacks run around migrations by RunMigrationsOnDb. Any of
+// them may be nil. They are not run when there is nothing to migrate, or for
+// dry runs.
+//
+// An error from BeforeRun or BeforeMigration stops the run before the
+// migration starts. AfterMigration is called whether or not the migration
+// failed, and AfterRun is called once BeforeRun has succeeded, whether or
+// not the run did; an error from either is returned along with any error
+// from the run, and an AfterMigration error stops the run after that
+// migration, which stays applied.
+type Hook struct {
+	BeforeRun       func(ctx context.Context, db *sql.DB, e RunEvent) error
+	AfterRun        func(ctx context.Context, db *sql.DB, e RunEvent) error
+	BeforeMigration func(ctx context.Context, db *sql.DB, e MigrationEvent) error
+	AfterMigration  func(ctx context.Context, db *sql.DB, e MigrationEvent) error
+
+	// forConf, set for hooks read from dbconf.yml, returns the hook's
+	// callbacks for the DBConf running them, which may be a copy made by
+	// ForTrack or for a fan-out target.
+	forConf func(c *DBConf) Hook
+}
+
+// hooks returns c.Hooks with every hook read from dbconf.yml bound to c.
+func (c *DBConf) hooks() []Hook {
+	hooks := make([]Hook, 0, len(c.Hooks))
+	for _, h := range c.Hooks {
+		if h.forConf != nil {
+			h = h.forConf(c)
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks
+}
+
+func (c *DBConf) beforeRun(ctx context.Context, db *sql.DB, e RunEvent) error {
+	for _, h := range c.hooks() {
+		if h.BeforeRun != nil {
+			if err := h.BeforeRun(ctx, db, e); err != nil {
+				return fmt.Errorf("goosedb: before-run hook: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *DBConf) afterRun(ctx context.Context, db *sql.DB, e RunEvent) error {
+	for _, h := range c.hooks() {
+		if h.AfterRun != nil {
+			if err := h.AfterRun(ctx, db, e); err != nil {
+				return fmt.Errorf("goosedb: after-run hook: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *DBConf) beforeMigration(ctx context.Context, db *sql.DB, e MigrationEvent) error {
+	for _, h := range c.hooks() {
+		if h.BeforeMigration != nil {
+			if err := h.BeforeMigration(ctx, db, e); err != nil {
+				return fmt.Errorf("goosedb: before-migration hook for version %d: %w", e.Version, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c *DBConf) afterMigration(ctx context.Context, db *sql.DB, e MigrationEvent) error {
+	for _, h := range c.hooks() {
+		if h.AfterMigration != nil {
+			if err := h.AfterMigration(ctx, db, e); err != nil {
+				return fmt.Errorf("goosedb: after-migration hook for version %d: %w", e.Version, err)
+			}
+		}
+	}
+	return nil
+}
+
+// environ returns the environment variables that describe e to command
+// hooks.
+func (e RunEvent) environ() []string {
+	env := []string{
+		"GOOSE_ENV=" + e.Env,
+		"GOOSE_DIRECTION=" + e.Direction,
+		fmt.Sprintf("GOOSE_CURRENT=%d", e.Current),
+		fmt.Sprintf("GOOSE_TARGET=%d", e.Target),
+		fmt.Sprintf("GOOSE_COUNT=%d", len(e.Migrations)),
+	}
+	return append(env, outcomeEnviron(e.Duration, e.Err)...)
+}
+
+// environ returns the environment variables that describe e to command
+// hooks.
+func (e MigrationEvent) environ() []string {
+	env := []string{
+		"GOOSE_ENV=" + e.Env,
+		"GOOSE_DIRECTION=" + e.Direction,
+		fmt.Sprintf("GOOSE_VERSION=%d", e.Version),
+		"GOOSE_FILE=" + filepath.Base(e.Source),
+	}
+	return append(env, outcomeEnviron(e.Duration, e.Err)...)
+}
+
+func outcomeEnviron(d time.Duration, err error) []string {
+	if d == 0 {
+		return nil
+	}
+	env := []string{"GOOSE_DURATION=" + d.String()}
+	if err != nil {
+		env = append(env, "GOOSE_ERROR="+err.Error())
+	}
+	return env
+}
+
+// hookEvents are the keys under "hooks" in dbconf.yml.
+var hookEvents = []string{"before_run", "after_run", "before_migration", "after_migration"}
+
+// readHooks returns the hooks configured for env in dbconf.yml, such as:
+//
+//	hooks:
+//	    after_run:
+//	        - sql: hooks/refresh_views.sql
+//	        - command: ./scripts/dump-schema.sh
+//
+// SQL file paths are relative to dir, the directory holding dbconf.yml.
+func readHooks(f *yaml.File, env, dir string) ([]Hook, error) {
+	var hooks []Hook
+	for _, event := range hookEvents {
+		spec := fmt.Sprintf("%s.hooks.%s", env, event)
+		n, err := f.Count(spec)
+		if err != nil {
+			continue
+		}
+		for i := 0; i < n; i++ {
+			item := fmt.Sprintf("%s[%d]", spec, i)
+			var action hookAction
+			if path, err := f.Get(item + ".sql"); err == nil {
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(dir, path)
+				}
+				action = func(ctx context.Context, conf *DBConf, db *sql.DB, _ []string) error {
+					return runSQLHook(ctx, conf, db, path)
+				}
+			} else if command, err := f.Get(item + ".command"); err == nil {
+				action = func(ctx context.Context, _ *DBConf, _ *sql.DB, environ []string) error {
+					return runCommandHook(ctx, command, environ)
+				}
+			} else {
+				return nil, fmt.Errorf("goose: %s in dbconf.yml needs a sql or command key", item)
+			}
+			hooks = append(hooks, configHook(event, action))
+		}
+	}
+	return hooks, nil
+}
+
+// hookAction is a hook from dbconf.yml, given the DBConf running it and
+// the environment variables describing the event.
+type hookAction func(ctx context.Context, conf *DBConf, db *sql.DB, environ []string) error
+
+// configHook returns a Hook that runs action for event.
+func configHook(event string, action hookAction) Hook {
+	return Hook{forConf: func(c *DBConf) Hook {
+		runAction := func(ctx context.Context, db *sql.DB, e RunEvent) error {
+			return action(ctx, c, db, e.environ())
+		}
+		migrationAction := func(ctx context.Context, db *sql.DB, e MigrationEvent) error {
+			return action(ctx, c, db, e.environ())
+		}
+		switch event {
+		case "before_run":
+			return Hook{BeforeRun: runAction}
+		case "after_run":
+			return Hook{AfterRun: runAction}
+		case "before_migration":
+			return Hook{BeforeMigration: migrationAction}
+		default:
+			return Hook{AfterMigration: migrationAction}
+		}
+	}}
+}
+
+// runSQLHook runs the statements in the SQL file at path, split on
+// semicolons and StatementBegin/StatementEnd like the Up section of a
+// migration, outside a transaction.
+func runSQLHook(ctx context.Context, conf *DBConf, db *sql.DB, path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	// parse the file as if it were an Up section; the annotation we add
+	// shifts every line number by one
+	stmts, err := parseSQLStatements(strings.NewReader(sqlCmdPrefix+"Up\n"+string(contents)), true)
+	if err != nil {
+		var pe *sqlParseError
+		if errors.As(err, &pe) && pe.line > 0 {
+			pe.line--
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, stmt := range stmts {
+		if err := execStatement(ctx, conf, db, stmt.sql); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, stmt.line-1, err)
+		}
+	}
+	return nil
+}
+
+// runCommandHook runs command with sh, with environ added to goose's
+// environment, and its output going to goose's.
+func runCommandHook(ctx context.Context, command string, environ []string) error {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(), environ...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%q: %w", command, err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHooks(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"002_two.sql": "-- +goose Up\nCREATE TABLE one (id int);\n\n-- +goose Down\n",
+	})
+
+	var events []string
+	conf.Hooks = append(conf.Hooks, Hook{
+		BeforeRun: func(ctx context.Context, db *sql.DB, e RunEvent) error {
+			events = append(events, fmt.Sprintf("before run %s %d->%d (%d)", e.Direction, e.Current, e.Target, len(e.Migrations)))
+			return nil
+		},
+		AfterRun: func(ctx context.Context, db *sql.DB, e RunEvent) error {
+			events = append(events, fmt.Sprintf("after run failed=%t", e.Err != nil))
+			return nil
+		},
+		BeforeMigration: func(ctx context.Context, db *sql.DB, e MigrationEvent) error {
+			events = append(events, fmt.Sprintf("before %d %s", e.Version, filepath.Base(e.Source)))
+			return nil
+		},
+		AfterMigration: func(ctx context.Context, db *sql.DB, e MigrationEvent) error {
+			events = append(events, fmt.Sprintf("after %d failed=%t timed=%t", e.Version, e.Err != nil, e.Duration > 0))
+			return nil
+		},
+	})
+
+	// 002 fails, because table one already exists
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db); err == nil {
+		t.Fatal("expected migration 002 to fail")
+	}
+	want := []string{
+		"before run up 0->2 (2)",
+		"before 1 001_one.sql",
+		"after 1 failed=false timed=true",
+		"before 2 002_two.sql",
+		"after 2 failed=true timed=true",
+		"after run failed=true",
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events:\ngot  %q\nwant %q", events, want)
+	}
+
+	// nothing to run, so no hooks
+	events = nil
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("hooks ran with nothing to migrate: %q", events)
+	}
+
+	// a failing before hook stops the run before anything changes
+	errVeto := errors.New("not now")
+	conf.Hooks = []Hook{{BeforeMigration: func(ctx context.Context, db *sql.DB, e MigrationEvent) error {
+		return errVeto
+	}}}
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 0, db); !errors.Is(err, errVeto) {
+		t.Fatalf("expected the hook's error, got %v", err)
+	}
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 1 {
+		t.Errorf("version after vetoed run: got %d, %v", v, err)
+	}
+}
+
+func TestConfigHooks(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "migrations"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "migrations", "001_one.sql"), []byte(tableMigration("one")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	refresh := "CREATE TABLE IF NOT EXISTS refreshed (n int);\nINSERT INTO refreshed VALUES (1);\n"
+	if err := os.WriteFile(filepath.Join(dir, "refresh.sql"), []byte(refresh), 0644); err != nil {
+		t.Fatal(err)
+	}
+	log := filepath.Join(dir, "hooks.log")
+	yml := fmt.Sprintf(`development:
+    driver: sqlite3
+    open: %s
+    hooks:
+        after_migration:
+            - command: echo "$GOOSE_DIRECTION $GOOSE_VERSION $GOOSE_FILE" >> %s
+        after_run:
+            - sql: refresh.sql
+`, filepath.Join(dir, "test.db"), log)
+	if err := os.WriteFile(filepath.Join(dir, "dbconf.yml"), []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := NewDBConf(dir, "development", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Logger = slog.New(slog.DiscardHandler)
+	if len(conf.Hooks) != 2 {
+		t.Fatalf("expected 2 hooks, got %d", len(conf.Hooks))
+	}
+	db, err := OpenDBFromDBConf(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "up 1 001_one.sql" {
+		t.Errorf("command hook wrote %q", got)
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM refreshed").Scan(&n); err != nil || n != 1 {
+		t.Errorf("sql hook: got %d rows, %v", n, err)
+	}
+
+	// a copy of conf, as made by ForTrack or for a fan-out target, runs the
+	// SQL hook with its own settings rather than the original's
+	conf.StatementTimeout = time.Nanosecond
+	c := *conf
+	c.StatementTimeout = 0
+	if err := RunMigrationsOnDb(&c, c.MigrationsDir, 0, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT count(*) FROM refreshed").Scan(&n); err != nil || n != 2 {
+		t.Errorf("sql hook on a copy: got %d rows, %v", n, err)
+	}
+}
@@ -0,0 +1,89 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrLockTimeout is returned when the migration lock could not be taken
+// within DBConf.LockTimeout.
+var ErrLockTimeout = errors.New("goosedb: timed out waiting for the migration lock")
+
+// lockRetryInterval is how long to wait between attempts to take the lock.
+var lockRetryInterval = 250 * time.Millisecond
+
+// acquireLock takes the migration lock for conf's dialect on a connection
+// dedicated to holding it, waiting up to conf.LockTimeout. The returned
+// function releases the lock and the connection.
+func acquireLock(ctx context.Context, conf *DBConf, db *sql.DB) (release func(), err error) {
+	d, ok := conf.Driver.Dialect.(LockingDialect)
+	if !ok {
+		return nil, fmt.Errorf("goosedb: the %T dialect does not support the migration lock", conf.Driver.Dialect)
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	waitCtx := ctx
+	if conf.LockTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, conf.LockTimeout)
+		defer cancel()
+	}
+
+	// fail closes conn and explains why the lock couldn't be taken.
+	fail := func(err error) error {
+		conn.Close()
+		if ctx.Err() == nil && waitCtx.Err() != nil {
+			return fmt.Errorf("%w after %v", ErrLockTimeout, conf.LockTimeout)
+		}
+		return fmt.Errorf("goosedb: taking migration lock: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		ok, err := d.TryLock(waitCtx, conn, conf.lockTable())
+		if err != nil {
+			return nil, fail(err)
+		}
+		if ok {
+			break
+		}
+		if attempt == 0 {
+			conf.logger().InfoContext(ctx, "goose: waiting for another migration to finish")
+		}
+		select {
+		case <-time.After(lockRetryInterval):
+		case <-waitCtx.Done():
+			return nil, fail(waitCtx.Err())
+		}
+	}
+
+	return func() {
+		// release even if ctx was canceled during the run
+		d.Unlock(context.WithoutCancel(ctx), conn, conf.lockTable())
+		conn.Close()
+	}, nil
+}
+
+// ClearLock releases the migration lock for conf, whoever holds it, after a
+// run was killed while holding it. It reports whether the lock was held.
+// Only sqlite3's lock can outlive its run; on Postgres and MySQL the lock is
+// released when the connection holding it closes, and ClearLock does
+// nothing.
+func ClearLock(conf *DBConf, db *sql.DB) (bool, error) {
+	return ClearLockContext(context.Background(), conf, db)
+}
+
+// ClearLockContext is like ClearLock but uses ctx for every query.
+func ClearLockContext(ctx context.Context, conf *DBConf, db *sql.DB) (bool, error) {
+	d, ok := conf.Driver.Dialect.(LockClearer)
+	if !ok {
+		return false, nil
+	}
+	return d.ClearLock(ctx, db, conf.lockTable())
+}
@@ -0,0 +1,102 @@
+package goosedb
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLockTimeout(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+	})
+	conf.Lock = true
+	conf.LockTimeout = 50 * time.Millisecond
+
+	release, err := acquireLock(context.Background(), conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db)
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout, got %v", err)
+	}
+
+	release()
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockConcurrentRuns(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"002_two.sql": tableMigration("two"),
+	})
+	conf.Lock = true
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM goose_db_version WHERE version_id > 0").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("expected each migration to be recorded once, got %d rows", n)
+	}
+}
+
+func TestSqliteLockPerVersionTable(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+	})
+	conf.LockTimeout = 50 * time.Millisecond
+	other := *conf
+	other.VersionTable = "other_db_version"
+
+	release, err := acquireLock(context.Background(), conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	// a separate version table has a lock of its own
+	releaseOther, err := acquireLock(context.Background(), &other, db)
+	if err != nil {
+		t.Fatalf("lock for another version table: %v", err)
+	}
+	releaseOther()
+
+	// a lock left by a killed run can be cleared
+	if _, err := acquireLock(context.Background(), conf, db); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout, got %v", err)
+	}
+	if cleared, err := ClearLock(conf, db); err != nil || !cleared {
+		t.Fatalf("ClearLock: got %t, %v", cleared, err)
+	}
+	releaseAgain, err := acquireLock(context.Background(), conf, db)
+	if err != nil {
+		t.Fatalf("lock after clearing: %v", err)
+	}
+	releaseAgain()
+	if cleared, err := ClearLock(conf, db); err != nil || cleared {
+		t.Errorf("ClearLock of a free lock: got %t, %v", cleared, err)
+	}
+}
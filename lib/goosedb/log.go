@@ -0,0 +1,44 @@
+package goosedb
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// defaultLogger reproduces goose's traditional output: the message of each
+// Info or Warn record on its own line on stdout, without attributes.
+var defaultLogger = slog.New(&messageHandler{w: os.Stdout})
+
+// logger returns the logger progress and warnings for conf should go to.
+func (c *DBConf) logger() *slog.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return defaultLogger
+}
+
+// messageHandler is a slog.Handler that writes only the message of each
+// record. Attributes and groups are discarded. Error records are skipped,
+// because the error is also returned to the caller, which reports it.
+type messageHandler struct {
+	mu     sync.Mutex
+	w      io.Writer
+	prefix string // written before each message
+}
+
+func (h *messageHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo && level < slog.LevelError
+}
+
+func (h *messageHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, h.prefix+r.Message+"\n")
+	return err
+}
+
+func (h *messageHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *messageHandler) WithGroup(string) slog.Handler      { return h }
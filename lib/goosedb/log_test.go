@@ -0,0 +1,50 @@
+package goosedb
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestMessageHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(&messageHandler{w: &buf})
+	log.Debug("hidden")
+	log.Info("OK    001_one.sql", "version", 1)
+	log.With("env", "test").Warn("careful")
+	log.Error("returned to the caller instead")
+
+	if got, want := buf.String(), "OK    001_one.sql\ncareful\n"; got != want {
+		t.Errorf("messageHandler output: got %q, want %q", got, want)
+	}
+}
+
+func TestRunMigrationsLogger(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+	})
+	var buf bytes.Buffer
+	conf.Logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []map[string]any
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var r map[string]any
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 log records, got %d: %v", len(records), records)
+	}
+	ok := records[1]
+	if ok["file"] != "001_one.sql" || ok["version"] != float64(1) || ok["duration"] == nil {
+		t.Errorf("unexpected record for applied migration: %v", ok)
+	}
+}
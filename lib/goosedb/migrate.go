@@ -1,12 +1,14 @@
 package goosedb
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"path/filepath"
-	"slices"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/kevinburke/goose/lib/goose"
 
@@ -18,6 +20,41 @@ import (
 
 var ErrTableDoesNotExist = errors.New("goosedb: table does not exist")
 
+// ErrNoAppliedVersion is returned when the version table has rows but no
+// record of any applied version, not even the initial version 0.
+var ErrNoAppliedVersion = errors.New("goosedb: version table has no applied versions")
+
+// ErrMixedNoTransaction is returned when a statement that cannot run in a
+// transaction, like CREATE INDEX CONCURRENTLY, shares its section with other
+// statements.
+var ErrMixedNoTransaction = errors.New("goosedb: statement cannot run in a transaction, but was paired with other statements; run it in isolation")
+
+// MigrationError describes a failure while running a single migration.
+type MigrationError struct {
+	Version int64
+	Source  string // path to the migration file
+
+	// StatementIndex is the index of the statement that failed among the
+	// statements for the migration's direction, or -1 if the failure did
+	// not happen while running a migration statement.
+	StatementIndex int
+	Statement      string
+
+	Err error // the underlying error, usually from the driver
+}
+
+func (e *MigrationError) Error() string {
+	if e.StatementIndex < 0 {
+		return fmt.Sprintf("%s (version %d): %v", filepath.Base(e.Source), e.Version, e.Err)
+	}
+	return fmt.Sprintf("%s (version %d) statement %d: %v\n%s",
+		filepath.Base(e.Source), e.Version, e.StatementIndex+1, e.Err, strings.TrimSpace(e.Statement))
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
 type migrationSorter []*goose.Migration
 
 // helpers so we can use pkg sort
@@ -46,67 +83,282 @@ func (ms migrationSorter) Sort(direction bool) {
 }
 
 // Runs migration on a specific database instance.
-func RunMigrationsOnDb(conf *DBConf, migrationsDir string, target int64, db *sql.DB) (err error) {
-	current, err := EnsureDBVersion(conf, db)
-	if err != nil {
-		return err
+//
+// When migrating up, any migration older than the current version that has
+// not been applied is considered missing. By default RunMigrationsOnDb refuses
+// to run and returns a *MissingMigrationsError listing them; if
+// conf.AllowMissing is set, the missing migrations are applied in version
+// order along with the rest.
+func RunMigrationsOnDb(conf *DBConf, migrationsDir string, target int64, db *sql.DB) error {
+	return RunMigrationsOnDbContext(context.Background(), conf, migrationsDir, target, db)
+}
+
+// RunMigrationsOnDbContext is like RunMigrationsOnDb but stops at the first
+// statement that is running when ctx is canceled. Each statement is also
+// bounded by conf.StatementTimeout, if set.
+//
+// If conf.Lock is set, the whole run, including creating the version table,
+// happens while holding the migration lock, so concurrent runs against the
+// same database wait for each other instead of racing.
+func RunMigrationsOnDbContext(ctx context.Context, conf *DBConf, migrationsDir string, target int64, db *sql.DB) (err error) {
+	if conf.Lock && conf.DryRun == nil {
+		release, err := acquireLock(ctx, conf, db)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	if conf.VerifyChecksums {
+		problems, err := VerifyChecksumsContext(ctx, conf, migrationsDir, db)
+		if err != nil {
+			return err
+		}
+		if len(problems) > 0 {
+			return &ChecksumError{Problems: problems}
+		}
 	}
 
-	migrations, err := goose.CollectMigrations(migrationsDir, current, target)
+	current, ms, err := planMigrationsOnDb(ctx, conf, migrationsDir, target, db)
 	if err != nil {
 		return err
 	}
 
-	if len(migrations) == 0 {
-		fmt.Printf("goose: no migrations to run. current version: %d\n", current)
+	if conf.DryRun != nil {
+		return dryRunMigrations(ctx, conf, db, current, target, ms)
+	}
+
+	log := conf.logger()
+
+	if len(ms) == 0 {
+		log.InfoContext(ctx, fmt.Sprintf("goose: no migrations to run. current version: %d", current),
+			"env", conf.Env, "current", current)
 		return nil
 	}
 
-	ms := migrationSorter(migrations)
-	direction := current < target
-	ms.Sort(direction)
+	// target == current still counts as up so missing migrations are found
+	direction := target >= current
+
+	log.InfoContext(ctx, fmt.Sprintf("goose: migrating db environment '%v', current version: %d, target: %d",
+		conf.Env, current, target),
+		"env", conf.Env, "track", conf.Track, "current", current, "target", target)
+
+	if direction && ms[0].Version < current {
+		log.WarnContext(ctx, "goose: applying migrations older than the current version",
+			"current", current, "first", ms[0].Version)
+	}
+
+	run := RunEvent{Env: conf.Env, Direction: directionName(direction), Current: current, Target: target, Migrations: ms}
+	if err := conf.beforeRun(ctx, db, run); err != nil {
+		return err
+	}
 
-	fmt.Printf("goose: migrating db environment '%v', current version: %d, target: %d\n",
-		conf.Env, current, target)
+	start := time.Now()
+	err = runMigrations(ctx, conf, db, ms, direction)
+	run.Duration, run.Err = time.Since(start), err
+	if err == nil {
+		log.DebugContext(ctx, "goose: migration complete",
+			"env", conf.Env, "target", target, "count", len(ms), "duration", run.Duration)
+	}
+
+	if hookErr := conf.afterRun(ctx, db, run); hookErr != nil {
+		return errors.Join(err, hookErr)
+	}
+	return err
+}
 
+// runMigrations runs ms in order, with the hooks around each, stopping at the
+// first failure.
+func runMigrations(ctx context.Context, conf *DBConf, db *sql.DB, ms migrationSorter, direction bool) (err error) {
+	log := conf.logger()
+	dir := directionName(direction)
 	for _, m := range ms {
+		name := filepath.Base(m.Source)
+		event := MigrationEvent{Env: conf.Env, Version: m.Version, Source: m.Source, Direction: dir}
+		if err := conf.beforeMigration(ctx, db, event); err != nil {
+			return err
+		}
 
-		switch filepath.Ext(m.Source) {
-		case ".sql":
-			err = runSQLMigration(conf, db, m.Source, m.Version, direction)
+		log.DebugContext(ctx, "goose: running migration", "version", m.Version, "file", name, "direction", dir)
+		migrationStart := time.Now()
+
+		switch {
+		case m.Go != nil:
+			err = runGoMigration(ctx, conf, db, m, direction)
+		case filepath.Ext(m.Source) == ".sql":
+			err = runSQLMigration(ctx, conf, db, m.Source, m.Version, direction)
 		}
 
+		event.Duration, event.Err = time.Since(migrationStart), err
+		hookErr := conf.afterMigration(ctx, db, event)
+
 		if err != nil {
-			return fmt.Errorf("FAIL %w, quitting migration", err)
+			log.ErrorContext(ctx, "FAIL  "+name,
+				"version", m.Version, "file", name, "direction", dir,
+				"duration", event.Duration, "outcome", OutcomeFail, "error", err)
+			return errors.Join(fmt.Errorf("FAIL %w, quitting migration", err), hookErr)
 		}
 
-		fmt.Println("OK   ", filepath.Base(m.Source))
+		log.InfoContext(ctx, "OK    "+name,
+			"version", m.Version, "file", name, "direction", dir,
+			"duration", event.Duration, "outcome", OutcomeOK)
+		if hookErr != nil {
+			return hookErr
+		}
 	}
-
 	return nil
 }
 
+// Values of the "outcome" attribute logged after each migration runs.
+const (
+	OutcomeOK   = "ok"
+	OutcomeFail = "fail"
+)
+
+// directionName returns "up" or "down" for the given direction.
+func directionName(direction bool) string {
+	if direction {
+		return "up"
+	}
+	return "down"
+}
+
+// PlanMigrationsOnDb returns the migrations that RunMigrationsOnDb would run
+// to move db to target, in the order they would run, without running them.
+func PlanMigrationsOnDb(conf *DBConf, migrationsDir string, target int64, db *sql.DB) ([]*goose.Migration, error) {
+	return PlanMigrationsOnDbContext(context.Background(), conf, migrationsDir, target, db)
+}
+
+// PlanMigrationsOnDbContext is like PlanMigrationsOnDb but uses ctx for the
+// queries against the version table.
+func PlanMigrationsOnDbContext(ctx context.Context, conf *DBConf, migrationsDir string, target int64, db *sql.DB) ([]*goose.Migration, error) {
+	_, ms, err := planMigrationsOnDb(ctx, conf, migrationsDir, target, db)
+	return ms, err
+}
+
+func planMigrationsOnDb(ctx context.Context, conf *DBConf, migrationsDir string, target int64, db *sql.DB) (int64, migrationSorter, error) {
+	current, err := EnsureDBVersionContext(ctx, conf, db)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	applied, err := appliedVersions(ctx, conf, db)
+	if err == ErrTableDoesNotExist && conf.DryRun != nil {
+		// only version 0 would be recorded
+		applied, err = map[int64]bool{0: true}, nil
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	all, err := conf.collectMigrations(migrationsDir, 0, maxVersion)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	migrations, err := planMigrations(all, applied, current, target, conf.AllowMissing)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ms := migrationSorter(migrations)
+	ms.Sort(target >= current)
+	return current, ms, nil
+}
+
+// maxVersion is the largest possible migration version, for collecting every
+// migration in a directory.
+const maxVersion = int64((1 << 63) - 1)
+
+// MissingMigrationsError is returned when migrating up and there are
+// migrations older than the current database version that have never been
+// applied, typically because they were merged from a long-lived branch.
+type MissingMigrationsError struct {
+	Current    int64
+	Migrations []*goose.Migration
+}
+
+func (e *MissingMigrationsError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "goosedb: found %d unapplied migration(s) older than current version %d",
+		len(e.Migrations), e.Current)
+	for _, m := range e.Migrations {
+		fmt.Fprintf(&b, "\n\t%d %s", m.Version, filepath.Base(m.Source))
+	}
+	b.WriteString("\nrerun with allow-missing to apply them out of order")
+	return b.String()
+}
+
+// planMigrations picks the migrations from all that must run to move the
+// database from current to target, given the set of applied versions.
+//
+// Migrating up selects every unapplied version up to target. Unapplied
+// versions at or below current are an error unless allowMissing is set.
+// Migrating down selects only the applied versions above target.
+func planMigrations(all []*goose.Migration, applied map[int64]bool, current, target int64, allowMissing bool) ([]*goose.Migration, error) {
+	var plan, missing []*goose.Migration
+	if target >= current {
+		for _, m := range all {
+			if m.Version > target || applied[m.Version] {
+				continue
+			}
+			if m.Version <= current {
+				missing = append(missing, m)
+				if !allowMissing {
+					continue
+				}
+			}
+			plan = append(plan, m)
+		}
+		if len(missing) > 0 && !allowMissing {
+			ms := migrationSorter(missing)
+			ms.Sort(true)
+			return nil, &MissingMigrationsError{Current: current, Migrations: missing}
+		}
+		return plan, nil
+	}
+
+	for _, m := range all {
+		if m.Version > target && m.Version <= current && applied[m.Version] {
+			plan = append(plan, m)
+		}
+	}
+	return plan, nil
+}
+
 func RunMigrations(conf *DBConf, migrationsDir string, target int64) error {
-	db, err := OpenDBFromDBConf(conf)
+	return RunMigrationsContext(context.Background(), conf, migrationsDir, target)
+}
+
+// RunMigrationsContext opens the database described by conf and runs
+// RunMigrationsOnDbContext against it.
+func RunMigrationsContext(ctx context.Context, conf *DBConf, migrationsDir string, target int64) error {
+	db, err := OpenDBFromDBConfContext(ctx, conf)
 	if err != nil {
 		return err
 	}
 	defer db.Close()
 
-	return RunMigrationsOnDb(conf, migrationsDir, target, db)
+	return RunMigrationsOnDbContext(ctx, conf, migrationsDir, target, db)
 }
 
 // wrapper for EnsureDBVersion for callers that don't already have
 // their own DB instance
 func GetDBVersion(conf *DBConf) (int64, error) {
+	return GetDBVersionContext(context.Background(), conf)
+}
 
-	db, err := OpenDBFromDBConf(conf)
+// GetDBVersionContext is like GetDBVersion but uses ctx for opening the
+// database and querying the version table.
+func GetDBVersionContext(ctx context.Context, conf *DBConf) (int64, error) {
+
+	db, err := OpenDBFromDBConfContext(ctx, conf)
 	if err != nil {
 		return -1, err
 	}
 	defer db.Close()
 
-	version, err := EnsureDBVersion(conf, db)
+	version, err := EnsureDBVersionContext(ctx, conf, db)
 	if err != nil {
 		return -1, err
 	}
@@ -114,70 +366,110 @@ func GetDBVersion(conf *DBConf) (int64, error) {
 	return version, nil
 }
 
-// Create the goose_db_version table
-// and insert the initial 0 value into it
-func createVersionTable(conf *DBConf, db *sql.DB) error {
-	txn, err := db.Begin()
-	if err != nil {
-		return err
-	}
-
-	d := conf.Driver.Dialect
-
-	if _, err := txn.Exec(d.createVersionTableSql()); err != nil {
-		txn.Rollback()
+// createVersionTable creates the version table and initializes it.
+func createVersionTable(ctx context.Context, conf *DBConf, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, conf.Driver.Dialect.CreateVersionTableSql(conf.versionTable())); err != nil {
 		return err
 	}
+	return initVersionTable(ctx, conf, db)
+}
 
-	version := 0
-	applied := true
-	if _, err := txn.Exec(d.insertVersionSql(), version, applied); err != nil {
-		txn.Rollback()
+// initVersionTable adds the newer columns to a version table that has no
+// rows and inserts the initial 0 value into it. Each step is separate, and
+// MySQL commits DDL as it runs anyway, so a run that fails partway leaves an
+// empty table, which the next run initializes again.
+func initVersionTable(ctx context.Context, conf *DBConf, db *sql.DB) error {
+	if err := upgradeVersionTable(ctx, conf, db); err != nil {
 		return err
 	}
-
-	return txn.Commit()
+	initial := versionRecord{version: 0, applied: true}
+	_, err := db.ExecContext(ctx, conf.insertVersionSql(), initial.args(conf)...)
+	return err
 }
 
 // EnsureDBVersion retrieves the current version for this DB, creating and
 // initializing the DB version table if it doesn't exist.
+//
+// The current version is the highest version whose most recent record marks
+// it as applied.
 func EnsureDBVersion(conf *DBConf, db *sql.DB) (int64, error) {
-	rows, err := conf.Driver.Dialect.dbVersionQuery(db)
+	return EnsureDBVersionContext(context.Background(), conf, db)
+}
+
+// EnsureDBVersionContext is like EnsureDBVersion but uses ctx for every query.
+//
+// If conf.DryRun is set, a missing version table is reported as version 0
+// and the table is neither created nor upgraded.
+func EnsureDBVersionContext(ctx context.Context, conf *DBConf, db *sql.DB) (int64, error) {
+	applied, err := appliedVersions(ctx, conf, db)
 	if err != nil {
 		if err == ErrTableDoesNotExist {
-			return 0, createVersionTable(conf, db)
+			if conf.DryRun != nil {
+				return 0, nil
+			}
+			return 0, createVersionTable(ctx, conf, db)
 		}
 		return 0, err
 	}
+
+	if len(applied) == 0 {
+		// a table left empty by a run that failed while creating it
+		var rows int64
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+conf.versionTable()).Scan(&rows); err != nil {
+			return 0, err
+		}
+		if rows > 0 || conf.DryRun != nil {
+			return 0, ErrNoAppliedVersion
+		}
+		return 0, initVersionTable(ctx, conf, db)
+	}
+
+	if conf.DryRun == nil {
+		if err := upgradeVersionTable(ctx, conf, db); err != nil {
+			return 0, err
+		}
+	}
+
+	current := int64(-1)
+	for v := range applied {
+		current = max(current, v)
+	}
+	return current, nil
+}
+
+// appliedVersions returns the set of versions whose most recent record in
+// the version table marks them as applied.
+func appliedVersions(ctx context.Context, conf *DBConf, db *sql.DB) (map[int64]bool, error) {
+	rows, err := conf.Driver.Dialect.DBVersionQuery(ctx, db, conf.versionTable())
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
 	// The most recent record for each migration specifies
 	// whether it has been applied or rolled back.
-	// The first version we find that has been applied is the current version.
-
-	toSkip := make([]int64, 0)
+	seen := make(map[int64]bool)
+	applied := make(map[int64]bool)
 
 	for rows.Next() {
 		var row goose.MigrationRecord
 		if err = rows.Scan(&row.VersionId, &row.IsApplied); err != nil {
-			return 0, fmt.Errorf("error scanning rows: %w", err)
+			return nil, fmt.Errorf("error scanning rows: %w", err)
 		}
 
-		// have we already marked this version to be skipped?
-		skip := slices.Contains(toSkip, row.VersionId)
-
-		if skip {
+		// only the latest record for a version counts
+		if seen[row.VersionId] {
 			continue
 		}
+		seen[row.VersionId] = true
 
-		// if version has been applied we're done
 		if row.IsApplied {
-			return row.VersionId, nil
+			applied[row.VersionId] = true
 		}
-
-		// latest version of migration has not been applied.
-		toSkip = append(toSkip, row.VersionId)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
-	panic("failure in EnsureDBVersion()")
+	return applied, nil
 }
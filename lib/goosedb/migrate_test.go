@@ -1,7 +1,15 @@
 package goosedb
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/kevinburke/goose/lib/goose"
 )
@@ -73,3 +81,326 @@ func validateMigrationSort(t *testing.T, ms migrationSorter, sorted []int64) {
 		}
 	}
 }
+
+// newSqliteTest returns a DBConf for a fresh sqlite3 database and a
+// migrations directory containing the given files.
+func newSqliteTest(t *testing.T, files map[string]string) (*DBConf, *sql.DB) {
+	t.Helper()
+	dir := t.TempDir()
+	migrationsDir := filepath.Join(dir, "migrations")
+	if err := os.Mkdir(migrationsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(migrationsDir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conf, err := NewConfig("sqlite3", filepath.Join(dir, "test.db"), migrationsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Logger = slog.New(slog.DiscardHandler)
+	db, err := OpenDBFromDBConf(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return conf, db
+}
+
+func tableMigration(table string) string {
+	return "-- +goose Up\nCREATE TABLE " + table + " (id int);\n\n-- +goose Down\nDROP TABLE " + table + ";\n"
+}
+
+func writeMigration(t *testing.T, conf *DBConf, name, body string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(conf.MigrationsDir, name), []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunMigrationsMissing(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql":   tableMigration("one"),
+		"003_three.sql": tableMigration("three"),
+	})
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 3, db); err != nil {
+		t.Fatal(err)
+	}
+
+	// a migration from a branch lands with an older version
+	writeMigration(t, conf, "002_two.sql", tableMigration("two"))
+
+	err := RunMigrationsOnDb(conf, conf.MigrationsDir, 3, db)
+	var missing *MissingMigrationsError
+	if !errors.As(err, &missing) {
+		t.Fatalf("expected MissingMigrationsError, got %v", err)
+	}
+	if len(missing.Migrations) != 1 || missing.Migrations[0].Version != 2 {
+		t.Errorf("unexpected missing migrations: %v", missing.Migrations)
+	}
+
+	conf.AllowMissing = true
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 3, db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("SELECT * FROM two"); err != nil {
+		t.Errorf("missing migration was not applied: %v", err)
+	}
+
+	current, err := EnsureDBVersion(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != 3 {
+		t.Errorf("EnsureDBVersion: got %d, want 3", current)
+	}
+
+	// rolling back 3 must not touch 2, even though 2 was applied last
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("SELECT * FROM three"); err == nil {
+		t.Error("expected table three to be dropped")
+	}
+	if _, err := db.Exec("SELECT * FROM two"); err != nil {
+		t.Errorf("table two should still exist: %v", err)
+	}
+}
+
+func TestPlanMigrationsOnDb(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql":   tableMigration("one"),
+		"002_two.sql":   tableMigration("two"),
+		"003_three.sql": tableMigration("three"),
+	})
+
+	plan, err := PlanMigrationsOnDb(conf, conf.MigrationsDir, 2, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 2 || plan[0].Version != 1 || plan[1].Version != 2 {
+		t.Fatalf("unexpected up plan: %v", plan)
+	}
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 3, db); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err = PlanMigrationsOnDb(conf, conf.MigrationsDir, 1, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 2 || plan[0].Version != 3 || plan[1].Version != 2 {
+		t.Fatalf("unexpected down plan: %v", plan)
+	}
+}
+
+func TestRunMigrationsCanceled(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := RunMigrationsOnDbContext(ctx, conf, conf.MigrationsDir, 1, db)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestRunMigrationsErrors(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"002_bad.sql": "-- +goose Up\nCREATE TABLE two (id int);\nINSERT INTO nope VALUES (1);\n",
+	})
+
+	err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db)
+	var merr *MigrationError
+	if !errors.As(err, &merr) {
+		t.Fatalf("expected MigrationError, got %v", err)
+	}
+	if merr.Version != 2 || merr.StatementIndex != 1 || !strings.Contains(merr.Statement, "nope") {
+		t.Errorf("unexpected MigrationError: %#v", merr)
+	}
+
+	// the failed migration's transaction was rolled back
+	current, err := EnsureDBVersion(conf, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current != 1 {
+		t.Errorf("EnsureDBVersion: got %d, want 1", current)
+	}
+
+	writeMigration(t, conf, "002_dup.sql", tableMigration("dup"))
+	err = RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db)
+	if !errors.Is(err, goose.ErrDuplicateVersion) {
+		t.Errorf("expected ErrDuplicateVersion, got %v", err)
+	}
+}
+
+func TestRunMigrationsMixedNoTransaction(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_idx.sql": "-- +goose Up\nCREATE TABLE one (id int);\nCREATE INDEX CONCURRENTLY one_id ON one (id);\n",
+	})
+
+	err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db)
+	if !errors.Is(err, ErrMixedNoTransaction) {
+		t.Fatalf("expected ErrMixedNoTransaction, got %v", err)
+	}
+}
+
+func TestRunMigrationsNoTransaction(t *testing.T) {
+	const broken = "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE TABLE one (id int);\nCREATE TABLE two (id int);\nCREATE TABLE one (id int);\n\n-- +goose Down\nDROP TABLE two;\nDROP TABLE one;\n"
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_notx.sql": broken,
+	})
+
+	// the third statement fails after the first two have run
+	err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db)
+	var merr *MigrationError
+	if !errors.As(err, &merr) || merr.StatementIndex != 2 {
+		t.Fatalf("expected statement 3 to fail, got %v", err)
+	}
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 0 {
+		t.Errorf("EnsureDBVersion: got %d, %v, want 0", v, err)
+	}
+	if done, _, err := readProgress(context.Background(), conf, Sqlite3Dialect{}, db, 1, true); err != nil || done != 2 {
+		t.Errorf("readProgress: got %d, %v, want 2", done, err)
+	}
+
+	// fixing the failed statement resumes after the first two
+	writeMigration(t, conf, "001_notx.sql", strings.Replace(broken, "CREATE TABLE one (id int);\n\n", "CREATE TABLE three (id int);\n\n", 1))
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("SELECT 1 FROM three"); err != nil {
+		t.Error(err)
+	}
+	if done, _, err := readProgress(context.Background(), conf, Sqlite3Dialect{}, db, 1, true); err != nil || done != 0 {
+		t.Errorf("expected progress to be cleared, got %d, %v", done, err)
+	}
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 1 {
+		t.Errorf("EnsureDBVersion: got %d, %v, want 1", v, err)
+	}
+
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 0, db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveProgressFailureKeepsProgress(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_notx.sql": "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE TABLE one (id int);\nCREATE TABLE two (id int);\nCREATE TABLE three (id int);\n",
+	})
+
+	// recording the second statement's progress fails
+	if _, err := db.Exec(createProgressTableSql(conf.progressTable())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TRIGGER fail_progress BEFORE INSERT ON goose_db_version_progress
+		WHEN NEW.statements_done = 2 BEGIN SELECT RAISE(ABORT, 'disk full'); END`); err != nil {
+		t.Fatal(err)
+	}
+	err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db)
+	if err == nil || !strings.Contains(err.Error(), "could not record progress") {
+		t.Fatalf("expected recording progress to fail, got %v", err)
+	}
+
+	// the progress of the first statement survives
+	if done, _, err := readProgress(context.Background(), conf, Sqlite3Dialect{}, db, 1, true); err != nil || done != 1 {
+		t.Errorf("readProgress: got %d, %v, want 1", done, err)
+	}
+}
+
+func TestRunMigrationsNoTransactionChanged(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_notx.sql": "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE TABLE one (id int);\nCREATE TABLE one (id int);\n",
+	})
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err == nil {
+		t.Fatal("expected the second statement to fail")
+	}
+
+	// the statement that already ran has changed, so resuming is unsafe
+	writeMigration(t, conf, "001_notx.sql", "-- +goose NO TRANSACTION\n-- +goose Up\nCREATE TABLE uno (id int);\nCREATE TABLE two (id int);\n")
+	err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db)
+	if err == nil || !strings.Contains(err.Error(), "goose_db_version_progress") {
+		t.Fatalf("expected an error about changed statements, got %v", err)
+	}
+}
+
+func TestSeparateVersionTables(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+	})
+	other := *conf
+	other.VersionTable = "other_versions"
+	writeMigration(t, conf, "002_two.sql", tableMigration("two"))
+
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+
+	// the other history starts from scratch, but shares the files
+	v, err := EnsureDBVersion(&other, db)
+	if err != nil || v != 0 {
+		t.Fatalf("EnsureDBVersion: got %d, %v, want 0", v, err)
+	}
+	plan, err := PlanMigrationsOnDb(&other, other.MigrationsDir, 2, db)
+	if err != nil || len(plan) != 2 {
+		t.Fatalf("PlanMigrationsOnDb: got %d migrations, %v, want 2", len(plan), err)
+	}
+
+	statuses, err := GetMigrationStatus(&other, other.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, st := range statuses {
+		if st.Applied {
+			t.Errorf("version %d is applied in the other table", st.Version)
+		}
+	}
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 1 {
+		t.Errorf("EnsureDBVersion: got %d, %v, want 1", v, err)
+	}
+}
+
+func TestGetMigrationStatus(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"002_two.sql": tableMigration("two"),
+	})
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := GetMigrationStatus(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 statuses, got %d", len(statuses))
+	}
+	if !statuses[0].Applied || statuses[0].AppliedAt.IsZero() {
+		t.Errorf("expected version 1 to be applied: %+v", statuses[0])
+	}
+	if statuses[1].Applied || !statuses[1].AppliedAt.IsZero() {
+		t.Errorf("expected version 2 to be pending: %+v", statuses[1])
+	}
+}
+
+func TestRunMigrationsFS(t *testing.T) {
+	conf, db := newSqliteTest(t, nil)
+	conf.MigrationsFS = fstest.MapFS{
+		"migrations/001_one.sql": {Data: []byte(tableMigration("one"))},
+		"migrations/002_two.sql": {Data: []byte(tableMigration("two"))},
+	}
+
+	if err := RunMigrationsOnDb(conf, "migrations", 2, db); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("SELECT * FROM two"); err != nil {
+		t.Errorf("migration from fs.FS was not applied: %v", err)
+	}
+}
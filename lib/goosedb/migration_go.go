@@ -0,0 +1,74 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/kevinburke/goose/lib/goose"
+)
+
+// Run a migration registered with goose.RegisterMigration or
+// goose.RegisterMigrationNoTx.
+//
+// Migrations registered with RegisterMigration run in a transaction with the
+// version table insert, as SQL migrations do. No-transaction migrations run
+// against db directly, and the version is recorded once they succeed. Go
+// migrations have no file, so no checksum is recorded for them.
+func runGoMigration(ctx context.Context, conf *DBConf, db *sql.DB, m *goose.Migration, direction bool) error {
+	fail := func(err error) error {
+		return &MigrationError{
+			Version:        m.Version,
+			Source:         m.Source,
+			StatementIndex: -1,
+			Err:            err,
+		}
+	}
+
+	g := m.Go
+	filename := filepath.Base(m.Source)
+	stmt := conf.insertVersionSql()
+	record := versionRecord{version: m.Version, applied: direction, filename: filename, start: time.Now()}
+
+	if g.NoTx {
+		fn := g.UpNoTx
+		if !direction {
+			fn = g.DownNoTx
+		}
+		if fn != nil {
+			if err := fn(ctx, db); err != nil {
+				return fail(err)
+			}
+		}
+		if _, err := db.ExecContext(ctx, stmt, record.args(conf)...); err != nil {
+			return fail(fmt.Errorf("ran the migration but could not record the version: %w", err))
+		}
+		return nil
+	}
+
+	fn := g.Up
+	if !direction {
+		fn = g.Down
+	}
+
+	txn, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fail(err)
+	}
+	if fn != nil {
+		if err := fn(ctx, txn); err != nil {
+			txn.Rollback()
+			return fail(err)
+		}
+	}
+	if _, err := txn.ExecContext(ctx, stmt, record.args(conf)...); err != nil {
+		txn.Rollback()
+		return fail(err)
+	}
+	if err := txn.Commit(); err != nil {
+		return fail(err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/kevinburke/goose/lib/goose"
+)
+
+func TestGoMigrations(t *testing.T) {
+	t.Cleanup(goose.ResetMigrations)
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"003_two.sql": tableMigration("two"),
+	})
+
+	// version 2 depends on table one and version 3 on version 2's column
+	goose.RegisterMigration(2, "add_column",
+		func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "ALTER TABLE one ADD COLUMN name text")
+			return err
+		},
+		func(ctx context.Context, tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, "ALTER TABLE one DROP COLUMN name")
+			return err
+		})
+	goose.RegisterMigrationNoTx(4, "backfill",
+		func(ctx context.Context, db *sql.DB) error {
+			_, err := db.ExecContext(ctx, "INSERT INTO one (id, name) VALUES (1, 'a')")
+			return err
+		},
+		nil)
+
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 4, db); err != nil {
+		t.Fatal(err)
+	}
+	var name string
+	if err := db.QueryRow("SELECT name FROM one WHERE id = 1").Scan(&name); err != nil || name != "a" {
+		t.Errorf("backfill: got %q, %v", name, err)
+	}
+
+	statuses, err := GetMigrationStatus(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 4 || statuses[1].Source != "2_add_column.go" || !statuses[1].Applied || !statuses[3].Applied {
+		t.Errorf("unexpected status: %+v", statuses)
+	}
+
+	// Go migrations have no file, so they never fail verification
+	problems, err := VerifyChecksums(conf, conf.MigrationsDir, db)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("VerifyChecksums: got %v, %v", problems, err)
+	}
+
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 1 {
+		t.Errorf("EnsureDBVersion: got %d, %v, want 1", v, err)
+	}
+	if _, err := db.Exec("SELECT name FROM one"); err == nil {
+		t.Error("expected the down migration to drop column name")
+	}
+}
+
+func TestGoMigrationFailure(t *testing.T) {
+	t.Cleanup(goose.ResetMigrations)
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+	})
+	boom := errors.New("boom")
+	goose.RegisterMigration(2, "fails",
+		func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO one (id) VALUES (1)"); err != nil {
+				return err
+			}
+			return boom
+		}, nil)
+
+	err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db)
+	var merr *MigrationError
+	if !errors.As(err, &merr) || merr.Version != 2 || !errors.Is(err, boom) {
+		t.Fatalf("expected a MigrationError wrapping boom, got %v", err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM one").Scan(&n); err != nil || n != 0 {
+		t.Errorf("expected the transaction to roll back, got %d rows, %v", n, err)
+	}
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 1 {
+		t.Errorf("EnsureDBVersion: got %d, %v, want 1", v, err)
+	}
+}
@@ -3,17 +3,49 @@ package goosedb
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
-	"log"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 )
 
-// Run a migration specified in raw SQL.
+// sqlMigration is a migration script prepared to run in one direction.
+type sqlMigration struct {
+	version   int64
+	direction bool
+	source    string
+	filename  string
+	checksum  string
+
+	statements []sqlStatement
+
+	// outsideTxn is set when the only statement cannot run in a
+	// transaction, so it runs on its own before the version is recorded.
+	outsideTxn bool
+
+	// noTransaction is set when the script is annotated with
+	// "-- +goose NO TRANSACTION", so every statement runs on its own.
+	noTransaction bool
+}
+
+// fail wraps err in a MigrationError for the migration. idx is the index of
+// the failing statement, or -1 if no statement was running.
+func (m *sqlMigration) fail(idx int, stmt string, err error) error {
+	return &MigrationError{
+		Version:        m.version,
+		Source:         m.source,
+		StatementIndex: idx,
+		Statement:      stmt,
+		Err:            err,
+	}
+}
+
+// prepareSQLMigration reads scriptFile, splits out the statements for
+// direction and chooses how to run them.
 //
 // Sections of the script can be annotated with a special comment,
 // starting with "-- +goose" to specify whether the section should
@@ -21,69 +53,113 @@ import (
 //
 // All statements following an Up or Down directive are grouped together
 // until another direction directive is found.
-func runSQLMigration(conf *DBConf, db *sql.DB, scriptFile string, v int64, direction bool) error {
-	f, err := os.Open(scriptFile)
+func prepareSQLMigration(conf *DBConf, scriptFile string, v int64, direction bool) (*sqlMigration, error) {
+	m := &sqlMigration{
+		version:   v,
+		direction: direction,
+		source:    scriptFile,
+		filename:  filepath.Base(scriptFile),
+	}
+
+	contents, err := conf.readMigration(scriptFile)
 	if err != nil {
-		return err
+		return nil, m.fail(-1, "", err)
 	}
-	defer f.Close()
+	m.checksum = checksumOf(contents)
 
-	// find each statement, checking annotations for up/down direction
-	// and execute each of them in the current transaction.
-	// Commits the transaction if successfully applied each statement and
-	// records the version into the version table or returns an error and
-	// rolls back the transaction.
-	stmts, err := splitSQLStatements(f, direction)
+	script, err := parseSQLScript(bytes.NewReader(contents), direction)
 	if err != nil {
-		return err
+		return nil, m.fail(-1, "", err)
 	}
+	m.statements = script.statements
+	m.noTransaction = script.noTransaction
 
-	// Choose query strategy
-	singleQueryOutsideTxn := false
-	for _, query := range stmts {
-		if cannotRunInTransaction(query) {
-			if len(stmts) > 1 {
-				log.Fatalf("Query %s cannot run in a transaction, but was paired with other queries; run it in isolation", query)
-			} else {
-				singleQueryOutsideTxn = true
+	// Choose query strategy. Without an annotation, a statement that
+	// cannot run in a transaction must be alone in its section.
+	if m.noTransaction {
+		return m, nil
+	}
+	for i, stmt := range m.statements {
+		if cannotRunInTransaction(stmt.sql) {
+			if len(m.statements) > 1 {
+				return nil, m.fail(i, stmt.sql, ErrMixedNoTransaction)
 			}
+			m.outsideTxn = true
 		}
 	}
+	return m, nil
+}
 
-	if singleQueryOutsideTxn {
-		if _, err = db.Exec(stmts[0]); err != nil {
-			log.Fatalf("FAIL %s (%v), quitting migration.", filepath.Base(scriptFile), err)
-			return err
+// Run a migration specified in raw SQL.
+//
+// Executes each statement in a transaction, records the version into the
+// version table and commits, or returns an error and rolls back the
+// transaction.
+func runSQLMigration(ctx context.Context, conf *DBConf, db *sql.DB, scriptFile string, v int64, direction bool) error {
+	start := time.Now()
+	m, err := prepareSQLMigration(conf, scriptFile, v, direction)
+	if err != nil {
+		return err
+	}
+
+	if m.noTransaction {
+		return runNoTransaction(ctx, conf, db, m, start)
+	}
+
+	stmt := conf.insertVersionSql()
+	record := versionRecord{version: v, applied: direction, filename: m.filename, checksum: m.checksum, start: start}
+
+	if m.outsideTxn {
+		query := m.statements[0].sql
+		if err = execStatement(ctx, conf, db, query); err != nil {
+			return m.fail(0, query, err)
 		}
-		stmt := conf.Driver.Dialect.insertVersionSql()
-		if _, err := db.Exec(stmt, v, direction); err != nil {
-			log.Printf("WARNING: Executed single query %s but could not commit the version bump %s; error was %s\n", stmts[0], stmt, err.Error())
+		if _, err := db.ExecContext(ctx, stmt, record.args(conf)...); err != nil {
+			return m.fail(-1, stmt, fmt.Errorf("executed the statement but could not record the version: %w", err))
 		}
-		return err
+		return nil
 	}
 
-	txn, err := db.Begin()
+	txn, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Fatal("db.Begin:", err)
+		return m.fail(-1, "", err)
 	}
 
-	for _, query := range stmts {
-		if _, err = txn.Exec(query); err != nil {
+	for i, s := range m.statements {
+		if err = execStatement(ctx, conf, txn, s.sql); err != nil {
 			txn.Rollback()
-			log.Fatalf("FAIL %s (%v), quitting migration.", filepath.Base(scriptFile), err)
-			return err
+			return m.fail(i, s.sql, err)
 		}
 	}
 
 	// Update the version table for the given migration,
 	// and finalize the transaction.
 	// XXX: drop goose_db_version table on some minimum version number?
-	stmt := conf.Driver.Dialect.insertVersionSql()
-	if _, err := txn.Exec(stmt, v, direction); err != nil {
+	if _, err := txn.ExecContext(ctx, stmt, record.args(conf)...); err != nil {
 		txn.Rollback()
-		return err
+		return m.fail(-1, stmt, err)
 	}
-	return txn.Commit()
+	if err := txn.Commit(); err != nil {
+		return m.fail(-1, "", err)
+	}
+	return nil
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// execStatement runs a single migration statement, bounded by
+// conf.StatementTimeout if it is set.
+func execStatement(ctx context.Context, conf *DBConf, db execer, query string) error {
+	if conf.StatementTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.StatementTimeout)
+		defer cancel()
+	}
+	_, err := db.ExecContext(ctx, query)
+	return err
 }
 
 const sqlCmdPrefix = "-- +goose "
@@ -139,6 +215,55 @@ func cannotRunInTransaction(query string) bool {
 // 'StatementBegin' and 'StatementEnd' to allow the script to
 // tell us to ignore semicolons.
 func splitSQLStatements(r io.Reader, direction bool) ([]string, error) {
+	parsed, err := parseSQLStatements(r, direction)
+	if err != nil {
+		return nil, err
+	}
+	stmts := make([]string, len(parsed))
+	for i, stmt := range parsed {
+		stmts[i] = stmt.sql
+	}
+	return stmts, nil
+}
+
+// sqlStatement is a single statement from a migration script.
+type sqlStatement struct {
+	sql  string
+	line int // line of the first SQL in the statement, starting at 1
+}
+
+// sqlParseError is a problem with the structure of a migration script.
+type sqlParseError struct {
+	line int // 0 if the problem isn't tied to a line
+	msg  string
+}
+
+func (e *sqlParseError) Error() string {
+	if e.line == 0 {
+		return e.msg
+	}
+	return fmt.Sprintf("line %d: %s", e.line, e.msg)
+}
+
+// parseSQLStatements is like splitSQLStatements but also records where each
+// statement starts.
+func parseSQLStatements(r io.Reader, direction bool) ([]sqlStatement, error) {
+	script, err := parseSQLScript(r, direction)
+	return script.statements, err
+}
+
+// sqlScript is one direction of a parsed migration script.
+type sqlScript struct {
+	statements []sqlStatement
+
+	// noTransaction is set by a "-- +goose NO TRANSACTION" annotation
+	// anywhere in the script. It applies to both directions.
+	noTransaction bool
+}
+
+// parseSQLScript is like parseSQLStatements but also reports the script's
+// file-level annotations.
+func parseSQLScript(r io.Reader, direction bool) (sqlScript, error) {
 	var buf bytes.Buffer
 	scanner := bufio.NewScanner(r)
 
@@ -151,10 +276,17 @@ func splitSQLStatements(r io.Reader, direction bool) ([]string, error) {
 	ignoreSemicolons := false
 	directionIsActive := false
 
-	stmts := make([]string, 0)
+	lineNum := 0
+	bufLine := 0   // first non-blank line in buf
+	stmtLine := 0  // first line of SQL in buf
+	beginLine := 0 // line of the open StatementBegin
+
+	stmts := make([]sqlStatement, 0)
+	noTransaction := false
 	for scanner.Scan() {
 
 		line := scanner.Text()
+		lineNum++
 
 		// handle any goose-specific commands
 		if strings.HasPrefix(line, sqlCmdPrefix) {
@@ -173,8 +305,12 @@ func splitSQLStatements(r io.Reader, direction bool) ([]string, error) {
 			case "StatementBegin":
 				if directionIsActive {
 					ignoreSemicolons = true
+					beginLine = lineNum
 				}
 
+			case "NO TRANSACTION":
+				noTransaction = true
+
 			case "StatementEnd":
 				if directionIsActive {
 					//lint:ignore S1002 would rather write it this way.
@@ -189,7 +325,15 @@ func splitSQLStatements(r io.Reader, direction bool) ([]string, error) {
 		}
 
 		if _, err := buf.WriteString(line + "\n"); err != nil {
-			return nil, fmt.Errorf("io err: %v", err)
+			return sqlScript{}, fmt.Errorf("io err: %v", err)
+		}
+		if trimmed := strings.TrimSpace(line); trimmed != "" {
+			if bufLine == 0 {
+				bufLine = lineNum
+			}
+			if stmtLine == 0 && !strings.HasPrefix(trimmed, "--") {
+				stmtLine = lineNum
+			}
 		}
 
 		// Wrap up the two supported cases: 1) basic with semicolon; 2) psql statement
@@ -197,28 +341,34 @@ func splitSQLStatements(r io.Reader, direction bool) ([]string, error) {
 		// do not conclude statement.
 		if (!ignoreSemicolons && endsWithSemicolon(line)) || statementEnded {
 			statementEnded = false
-			stmts = append(stmts, buf.String())
+			stmts = append(stmts, sqlStatement{sql: buf.String(), line: stmtLine})
 			buf.Reset()
+			bufLine, stmtLine = 0, 0
 		}
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("scanning migration: %v", err)
+		return sqlScript{}, fmt.Errorf("scanning migration: %v", err)
 	}
 
 	// diagnose likely migration script errors
 	if ignoreSemicolons {
-		return nil, fmt.Errorf("saw '-- +goose StatementBegin' with no matching '-- +goose StatementEnd'")
+		return sqlScript{}, &sqlParseError{beginLine, "saw '-- +goose StatementBegin' with no matching '-- +goose StatementEnd'"}
 	}
 
 	if bufferRemaining := strings.TrimSpace(buf.String()); len(bufferRemaining) > 0 {
-		return nil, fmt.Errorf("unexpected unfinished SQL query: %s. Missing a semicolon?", bufferRemaining)
+		// point at the SQL, or at the comments if there is none
+		line := stmtLine
+		if line == 0 {
+			line = bufLine
+		}
+		return sqlScript{}, &sqlParseError{line, fmt.Sprintf("unexpected unfinished SQL query: %s. Missing a semicolon?", bufferRemaining)}
 	}
 
 	if upSections == 0 && downSections == 0 {
-		return nil, fmt.Errorf(`no Up/Down annotations found, so no statements were executed.
-See https://github.com/kevinburke/goose for details`)
+		return sqlScript{}, &sqlParseError{0, `no Up/Down annotations found, so no statements were executed.
+See https://github.com/kevinburke/goose for details`}
 	}
 
-	return stmts, nil
+	return sqlScript{statements: stmts, noTransaction: noTransaction}, nil
 }
@@ -189,3 +189,25 @@ CREATE TABLE fancier_post (
 -- +goose Down
 DROP TABLE fancier_post;
 `
+
+func TestParseStatementLines(t *testing.T) {
+	stmts, err := parseSQLStatements(strings.NewReader(functxt), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 2 || stmts[0].line != 2 || stmts[1].line != 9 {
+		t.Errorf("unexpected statement lines: %+v", stmts)
+	}
+
+	// trailing comments are an unfinished statement, reported where they
+	// start
+	_, err = parseSQLStatements(strings.NewReader("-- +goose Up\nSELECT 1;\n-- +goose Down\n-- nothing\n"), false)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 3: unexpected unfinished SQL query") {
+		t.Errorf("expected an unfinished query on line 3, got %v", err)
+	}
+
+	_, err = parseSQLStatements(strings.NewReader("-- +goose Up\nSELECT 1;\n\nSELECT 2\n"), true)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 4: ") {
+		t.Errorf("expected error on line 4, got %v", err)
+	}
+}
@@ -1,8 +1,11 @@
 package goosedb
 
 import (
+	"context"
 	"database/sql"
+	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
 )
 
@@ -15,3 +18,58 @@ import (
 func init() {
 	sql.Register("postgres", &stdlib.Driver{})
 }
+
+// pgSchemas returns the schemas in conf.PgSchema, a comma-separated search
+// path such as "tenant_a, public".
+func (c *DBConf) pgSchemas() []string {
+	if c.Driver.Name != "postgres" {
+		return nil
+	}
+	var schemas []string
+	for _, s := range strings.Split(c.PgSchema, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			schemas = append(schemas, s)
+		}
+	}
+	return schemas
+}
+
+// searchPathSql returns the statement that sets the search path to schemas,
+// each quoted so that any name is safe.
+func searchPathSql(schemas []string) string {
+	quoted := make([]string, len(schemas))
+	for i, s := range schemas {
+		quoted[i] = pgx.Identifier{s}.Sanitize()
+	}
+	return "SET search_path TO " + strings.Join(quoted, ", ")
+}
+
+// openPostgresSchema opens conf's database with the search path of every
+// connection in the pool set to conf.PgSchema, creating the first schema
+// first if conf.CreatePgSchema is set.
+func openPostgresSchema(ctx context.Context, conf *DBConf) (*sql.DB, error) {
+	config, err := pgx.ParseConfig(conf.Driver.OpenStr)
+	if err != nil {
+		return nil, err
+	}
+	schemas := conf.pgSchemas()
+	setPath := searchPathSql(schemas)
+	db := stdlib.OpenDB(*config, stdlib.OptionAfterConnect(func(ctx context.Context, c *pgx.Conn) error {
+		_, err := c.Exec(ctx, setPath)
+		return err
+	}))
+
+	// connect now, like sql.Open followed by a statement would, so that a
+	// bad connection string or search path fails here
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if conf.CreatePgSchema && conf.DryRun == nil {
+		if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+pgx.Identifier{schemas[0]}.Sanitize()); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
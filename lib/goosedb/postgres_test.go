@@ -0,0 +1,34 @@
+package goosedb
+
+import "testing"
+
+func TestPgSchema(t *testing.T) {
+	conf := &DBConf{
+		Driver:   DBDriver{Name: "postgres", Dialect: &PostgresDialect{}},
+		PgSchema: ` tenant_a , public,`,
+	}
+	if got, want := searchPathSql(conf.pgSchemas()), `SET search_path TO "tenant_a", "public"`; got != want {
+		t.Errorf("searchPathSql: got %s, want %s", got, want)
+	}
+	if got := searchPathSql([]string{`x"; DROP TABLE users; --`}); got != `SET search_path TO "x""; DROP TABLE users; --"` {
+		t.Errorf("searchPathSql did not quote: %s", got)
+	}
+
+	// the version table lives in the first schema, unless one is given
+	if got := conf.versionTable(); got != `"tenant_a"."goose_db_version"` {
+		t.Errorf("versionTable: got %s", got)
+	}
+	if got := conf.versionTableName(); got != "tenant_a.goose_db_version" {
+		t.Errorf("versionTableName: got %s", got)
+	}
+	conf.VersionSchema = "goose"
+	if got := conf.versionTable(); got != `"goose"."goose_db_version"` {
+		t.Errorf("versionTable with VersionSchema: got %s", got)
+	}
+
+	// PgSchema only applies to postgres
+	sqlite := &DBConf{Driver: DBDriver{Name: "sqlite3", Dialect: &Sqlite3Dialect{}}, PgSchema: "tenant_a"}
+	if got := sqlite.versionTable(); got != `"goose_db_version"` {
+		t.Errorf("sqlite3 versionTable: got %s", got)
+	}
+}
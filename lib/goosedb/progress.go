@@ -0,0 +1,127 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// createProgressTableSql creates the table that records how far a
+// NO TRANSACTION migration has got, for the built-in dialects. It holds at most one row per version and
+// direction, which is removed once the migration is recorded in the version
+// table.
+func createProgressTableSql(table string) string {
+	return `CREATE TABLE IF NOT EXISTS ` + table + ` (
+                version_id bigint NOT NULL,
+                is_applied boolean NOT NULL,
+                statements_done int NOT NULL,
+                checksum varchar(64) NOT NULL
+            )`
+}
+
+// statementsChecksum returns the checksum of stmts, used to check that the
+// statements an earlier run completed have not changed before resuming.
+func statementsChecksum(stmts []sqlStatement) string {
+	var b strings.Builder
+	for _, stmt := range stmts {
+		b.WriteString(stmt.sql)
+	}
+	return checksumOf([]byte(b.String()))
+}
+
+// readProgress returns the number of statements an earlier run of version v
+// completed in direction, and the checksum of those statements. It returns
+// 0 if there is no earlier run to resume.
+func readProgress(ctx context.Context, conf *DBConf, d ProgressDialect, db *sql.DB, v int64, direction bool) (int, string, error) {
+	var done int
+	var checksum string
+	err := db.QueryRowContext(ctx, d.SelectProgressSql(conf.progressTable()), v, direction).Scan(&done, &checksum)
+	if err == sql.ErrNoRows {
+		return 0, "", nil
+	}
+	return done, checksum, err
+}
+
+// saveProgress records that the first done statements of version v have
+// completed in direction. The old row is replaced in a transaction, so a
+// crash can't lose the progress already made.
+func saveProgress(ctx context.Context, conf *DBConf, d ProgressDialect, db *sql.DB, v int64, direction bool, done int, checksum string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := clearProgress(ctx, conf, d, tx, v, direction); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, d.InsertProgressSql(conf.progressTable()), v, direction, done, checksum); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// clearProgress removes the progress recorded for version v in direction.
+func clearProgress(ctx context.Context, conf *DBConf, d ProgressDialect, db execer, v int64, direction bool) error {
+	_, err := db.ExecContext(ctx, d.DeleteProgressSql(conf.progressTable()), v, direction)
+	return err
+}
+
+// runNoTransaction runs a migration annotated with NO TRANSACTION. Each
+// statement runs on its own, and progress is recorded after each one, so
+// that if a statement fails, running the migration again resumes with it.
+func runNoTransaction(ctx context.Context, conf *DBConf, db *sql.DB, m *sqlMigration, start time.Time) error {
+	d, ok := conf.Driver.Dialect.(ProgressDialect)
+	if !ok {
+		return m.fail(-1, "", fmt.Errorf("the %T dialect does not support NO TRANSACTION migrations", conf.Driver.Dialect))
+	}
+	if _, err := db.ExecContext(ctx, d.CreateProgressTableSql(conf.progressTable())); err != nil {
+		return m.fail(-1, "", err)
+	}
+
+	done, checksum, err := readProgress(ctx, conf, d, db, m.version, m.direction)
+	if err != nil {
+		return m.fail(-1, "", err)
+	}
+	if done > 0 {
+		if done > len(m.statements) || statementsChecksum(m.statements[:done]) != checksum {
+			return m.fail(-1, "", fmt.Errorf("an earlier run completed %d statement(s), but they have changed since; "+
+				"once the database matches the file, delete the migration's row from %s_progress", done, conf.versionTableName()))
+		}
+		conf.logger().InfoContext(ctx, fmt.Sprintf("goose: resuming %s after statement %d of %d", m.filename, done, len(m.statements)),
+			"version", m.version, "file", m.filename, "direction", directionName(m.direction), "statements_done", done)
+	}
+
+	for i := done; i < len(m.statements); i++ {
+		query := m.statements[i].sql
+		if err := execStatement(ctx, conf, db, query); err != nil {
+			return m.fail(i, query, err)
+		}
+		if err := saveProgress(ctx, conf, d, db, m.version, m.direction, i+1, statementsChecksum(m.statements[:i+1])); err != nil {
+			return m.fail(-1, "", fmt.Errorf("executed statement %d but could not record progress: %w", i+1, err))
+		}
+		conf.logger().DebugContext(ctx, "goose: ran statement",
+			"version", m.version, "file", m.filename, "direction", directionName(m.direction), "statement", i+1)
+	}
+
+	// record the version and clear the progress together, so that a later
+	// run in this direction never resumes from a finished run's progress
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return m.fail(-1, "", err)
+	}
+	defer tx.Rollback()
+	stmt := conf.insertVersionSql()
+	record := versionRecord{version: m.version, applied: m.direction, filename: m.filename, checksum: m.checksum, start: start}
+	if _, err := tx.ExecContext(ctx, stmt, record.args(conf)...); err != nil {
+		return m.fail(-1, stmt, fmt.Errorf("executed every statement but could not record the version: %w", err))
+	}
+	if err := clearProgress(ctx, conf, d, tx, m.version, m.direction); err != nil {
+		return m.fail(-1, "", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return m.fail(-1, "", fmt.Errorf("executed every statement but could not record the version: %w", err))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/kevinburke/goose/lib/goose"
+)
+
+// MigrationStatus describes the state of a single migration in the
+// migrations directory.
+type MigrationStatus struct {
+	Version int64
+	Source  string // path to the migration file, or name of a Go migration
+	Applied bool
+
+	// Baselined is set if the migration was recorded as applied by
+	// Baseline rather than run.
+	Baselined bool
+
+	// AppliedAt is when the migration was applied: in UTC, or in the
+	// database server's time zone for migrations applied by versions of
+	// goose that didn't record UTC. It is the zero Time if the migration is
+	// pending.
+	AppliedAt time.Time
+
+	// Duration, AppliedBy, Hostname and GooseVersion describe the run that
+	// applied the migration: how long it took, the user and host that ran
+	// it and the version of goose. They are zero if the migration is
+	// pending or was applied before goose recorded them, and Duration is
+	// also zero for baselined migrations.
+	Duration     time.Duration
+	AppliedBy    string
+	Hostname     string
+	GooseVersion string
+}
+
+// GetMigrationStatus returns the status of every migration in migrationsDir,
+// ordered by version, creating the version table if it doesn't exist.
+func GetMigrationStatus(conf *DBConf, migrationsDir string, db *sql.DB) ([]MigrationStatus, error) {
+	return GetMigrationStatusContext(context.Background(), conf, migrationsDir, db)
+}
+
+// GetMigrationStatusContext is like GetMigrationStatus but uses ctx for every
+// query.
+func GetMigrationStatusContext(ctx context.Context, conf *DBConf, migrationsDir string, db *sql.DB) ([]MigrationStatus, error) {
+	migrations, err := conf.collectMigrations(migrationsDir, 0, maxVersion)
+	if err != nil {
+		return nil, err
+	}
+	ms := migrationSorter(migrations)
+	ms.Sort(true)
+
+	// must ensure that the version table exists if we're running on a pristine DB
+	if _, err := EnsureDBVersionContext(ctx, conf, db); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(ms))
+	for _, m := range ms {
+		st := MigrationStatus{Version: m.Version, Source: m.Source}
+
+		var row goose.MigrationRecord
+		var baselined sql.NullBool
+		var durationMS sql.NullInt64
+		var appliedBy, hostname, gooseVersion sql.NullString
+		var tstampUTC sql.NullTime
+		q := fmt.Sprintf("SELECT tstamp, is_applied, baselined, duration_ms, applied_by, hostname, goose_version, tstamp_utc FROM %s WHERE version_id=%d ORDER BY id DESC LIMIT 1", conf.versionTable(), m.Version)
+		err := db.QueryRowContext(ctx, q).Scan(&row.TStamp, &row.IsApplied, &baselined,
+			&durationMS, &appliedBy, &hostname, &gooseVersion, &tstampUTC)
+		if err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		if row.IsApplied {
+			st.Applied = true
+			st.Baselined = baselined.Bool
+			st.AppliedAt = row.TStamp
+			if tstampUTC.Valid {
+				st.AppliedAt = tstampUTC.Time.UTC()
+			}
+			st.Duration = time.Duration(durationMS.Int64) * time.Millisecond
+			st.AppliedBy = appliedBy.String
+			st.Hostname = hostname.String
+			st.GooseVersion = gooseVersion.String
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
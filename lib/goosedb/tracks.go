@@ -0,0 +1,93 @@
+package goosedb
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kylelemons/go-gypsy/yaml"
+)
+
+// Track is a named sequence of migrations with its own directory and version
+// history, such as seed data or the schema owned by a plugin, that evolves
+// independently of the default track in DBConf.MigrationsDir.
+type Track struct {
+	MigrationsDir string
+
+	// VersionTable records the track's applied migrations. If empty, it is
+	// the default track's version table with "_<track name>" appended.
+	VersionTable string
+}
+
+// ForTrack returns a copy of c that runs the migrations of the named track,
+// from c.Tracks, instead of the default track. Selecting the track c already
+// runs returns c.
+func (c *DBConf) ForTrack(name string) (*DBConf, error) {
+	if name == c.Track {
+		return c, nil
+	}
+	if c.Track != "" {
+		return nil, fmt.Errorf("goose: cannot select track %q from track %q", name, c.Track)
+	}
+	t, ok := c.Tracks[name]
+	if !ok {
+		names := make([]string, 0, len(c.Tracks))
+		for n := range c.Tracks {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("goose: unknown track %q; no tracks are configured", name)
+		}
+		return nil, fmt.Errorf("goose: unknown track %q; configured tracks: %s", name, strings.Join(names, ", "))
+	}
+
+	tc := *c
+	tc.Track = name
+	tc.MigrationsDir = t.MigrationsDir
+	tc.VersionTable = t.VersionTable
+	if tc.VersionTable == "" {
+		tc.VersionTable = c.versionTableBase() + "_" + name
+	}
+	return &tc, nil
+}
+
+// readTracks returns the tracks configured for env in dbconf.yml, such as:
+//
+//	tracks:
+//	    seeds:
+//	        dir: seeds
+//	    billing:
+//	        dir: plugins/billing/migrations
+//	        versiontable: billing_db_version
+//
+// Directories are relative to dir, the directory holding dbconf.yml, and
+// default to the track's name.
+func readTracks(f *yaml.File, env, dir string) (map[string]Track, error) {
+	node, err := yaml.Child(f.Root, env+".tracks")
+	if err != nil || node == nil {
+		return nil, nil
+	}
+	m, ok := node.(yaml.Map)
+	if !ok {
+		return nil, fmt.Errorf("goose: %s.tracks in dbconf.yml must map track names to their settings", env)
+	}
+
+	tracks := make(map[string]Track, len(m))
+	for name := range m {
+		spec := fmt.Sprintf("%s.tracks.%s", env, name)
+		t := Track{MigrationsDir: name}
+		if d, err := f.Get(spec + ".dir"); err == nil {
+			t.MigrationsDir = d
+		}
+		if !filepath.IsAbs(t.MigrationsDir) {
+			t.MigrationsDir = filepath.Join(dir, t.MigrationsDir)
+		}
+		if table, err := f.Get(spec + ".versiontable"); err == nil {
+			t.VersionTable = table
+		}
+		tracks[name] = t
+	}
+	return tracks, nil
+}
@@ -0,0 +1,111 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kevinburke/goose/lib/goose"
+)
+
+func TestTracks(t *testing.T) {
+	t.Cleanup(goose.ResetMigrations)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"migrations/001_users.sql":              tableMigration("users"),
+		"seeds/001_admin.sql":                   "-- +goose Up\nINSERT INTO users VALUES (1);\n\n-- +goose Down\nDELETE FROM users;\n",
+		"plugins/billing/001_invoices.sql":      tableMigration("invoices"),
+		"plugins/billing/002_invoice_lines.sql": tableMigration("invoice_lines"),
+	}
+	for name, body := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	yml := fmt.Sprintf(`development:
+    driver: sqlite3
+    open: %s
+    tracks:
+        seeds:
+            dir: seeds
+        billing:
+            dir: plugins/billing
+            versiontable: billing_db_version
+`, filepath.Join(dir, "test.db"))
+	if err := os.WriteFile(filepath.Join(dir, "dbconf.yml"), []byte(yml), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	conf, err := NewDBConf(dir, "development", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Logger = slog.New(slog.DiscardHandler)
+	db, err := OpenDBFromDBConf(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	seeds, err := conf.ForTrack("seeds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	billing, err := conf.ForTrack("billing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seeds.versionTableName() != "goose_db_version_seeds" || billing.versionTableName() != "billing_db_version" {
+		t.Errorf("track tables: %s, %s", seeds.versionTableName(), billing.versionTableName())
+	}
+	if _, err := conf.ForTrack("reports"); err == nil || !strings.Contains(err.Error(), "billing, seeds") {
+		t.Errorf("unknown track: got %v", err)
+	}
+
+	// a Go migration registered on a track runs only there
+	ran := 0
+	goose.RegisterTrackMigration("billing", 3, "backfill", func(ctx context.Context, tx *sql.Tx) error {
+		ran++
+		return nil
+	}, nil)
+
+	// every track starts at version 1 without colliding
+	for _, c := range []*DBConf{conf, seeds, billing} {
+		if err := RunMigrationsOnDb(c, c.MigrationsDir, 3, db); err != nil {
+			t.Fatalf("track %q: %v", c.Track, err)
+		}
+	}
+	if ran != 1 {
+		t.Errorf("billing Go migration ran %d times", ran)
+	}
+	for _, tt := range []struct {
+		conf *DBConf
+		want int64
+	}{{conf, 1}, {seeds, 1}, {billing, 3}} {
+		if v, err := EnsureDBVersion(tt.conf, db); err != nil || v != tt.want {
+			t.Errorf("track %q version: got %d, %v, want %d", tt.conf.Track, v, err, tt.want)
+		}
+	}
+
+	// rolling back seeds leaves the other histories alone
+	if err := RunMigrationsOnDb(seeds, seeds.MigrationsDir, 0, db); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 1 {
+		t.Errorf("default track after seeds rollback: got %d, %v", v, err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM users").Scan(&n); err != nil || n != 0 {
+		t.Errorf("users after seeds rollback: got %d, %v", n, err)
+	}
+}
@@ -0,0 +1,125 @@
+package goosedb
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/kevinburke/goose/lib/goose"
+)
+
+// ValidationProblem describes one problem found in a migration file.
+type ValidationProblem struct {
+	Source  string // path to the migration file
+	Line    int    // line number starting at 1, or 0 if not tied to a line
+	Message string
+}
+
+func (p ValidationProblem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Source, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", p.Source, p.Line, p.Message)
+}
+
+// ValidateMigrations checks every migration in migrationsDir without
+// connecting to a database, and returns every problem it finds. It reports
+// .sql files whose names aren't valid migration names, duplicate versions,
+// Up or Down sections that can't be split into statements, empty Down
+// sections, and statements that cannot run in a transaction paired with
+// other statements.
+//
+// The error is only non-nil if the directory or a file could not be read.
+func ValidateMigrations(migrationsDir string) ([]ValidationProblem, error) {
+	files, err := goose.MigrationFiles(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	return validateMigrations(files, func(name string) (io.ReadCloser, error) {
+		return os.Open(name)
+	})
+}
+
+// ValidateMigrationsFS is like ValidateMigrations but reads migrationsDir
+// from fsys.
+func ValidateMigrationsFS(fsys fs.FS, migrationsDir string) ([]ValidationProblem, error) {
+	files, err := goose.MigrationFilesFS(fsys, migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	return validateMigrations(files, func(name string) (io.ReadCloser, error) {
+		return fsys.Open(name)
+	})
+}
+
+func validateMigrations(files []string, open func(string) (io.ReadCloser, error)) ([]ValidationProblem, error) {
+	var problems []ValidationProblem
+	report := func(source string, line int, format string, args ...any) {
+		problems = append(problems, ValidationProblem{source, line, fmt.Sprintf(format, args...)})
+	}
+
+	seen := make(map[int64]string)
+	for _, name := range files {
+		v, err := goose.NumericComponent(name)
+		if err != nil {
+			report(name, 0, "not a valid migration name: %v", err)
+			continue
+		}
+		if prev, ok := seen[v]; ok {
+			report(name, 0, "version %d is also used by %s", v, prev)
+		} else {
+			seen[v] = name
+		}
+
+		for _, direction := range []bool{true, false} {
+			script, err := parseMigration(name, open, direction)
+			if err != nil {
+				var perr *sqlParseError
+				if !errors.As(err, &perr) {
+					return nil, err
+				}
+				if perr.line == 0 {
+					// a problem with the whole file, which would be
+					// reported again for the other direction
+					report(name, 0, "%s", perr.msg)
+					break
+				}
+				report(name, perr.line, "%s section: %s", sectionName(direction), perr.msg)
+				continue
+			}
+
+			stmts := script.statements
+			if !direction && len(stmts) == 0 {
+				report(name, 0, "Down section is missing or has no statements")
+			}
+
+			if len(stmts) > 1 && !script.noTransaction {
+				for _, stmt := range stmts {
+					if cannotRunInTransaction(stmt.sql) {
+						report(name, stmt.line, "%s section: statement cannot run in a transaction, but is paired with other statements",
+							sectionName(direction))
+					}
+				}
+			}
+		}
+	}
+	return problems, nil
+}
+
+func parseMigration(name string, open func(string) (io.ReadCloser, error), direction bool) (sqlScript, error) {
+	f, err := open(name)
+	if err != nil {
+		return sqlScript{}, err
+	}
+	defer f.Close()
+	return parseSQLScript(f, direction)
+}
+
+func sectionName(direction bool) string {
+	if direction {
+		return "Up"
+	}
+	return "Down"
+}
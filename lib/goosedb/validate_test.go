@@ -0,0 +1,51 @@
+package goosedb
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestValidateMigrationsFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/001_ok.sql":        {Data: []byte(tableMigration("ok"))},
+		"m/002_nodown.sql":    {Data: []byte("-- +goose Up\nCREATE TABLE a (id int);\n\n-- +goose Down\n-- nothing to do\n")},
+		"m/002_dup.sql":       {Data: []byte(tableMigration("dup"))},
+		"m/003_semi.sql":      {Data: []byte("-- +goose Up\nCREATE TABLE b (id int);\nCREATE TABLE c (id int)\n-- +goose Down\nDROP TABLE c;\n")},
+		"m/004_index.sql":     {Data: []byte("-- +goose Up\nCREATE TABLE d (id int);\n\nCREATE INDEX CONCURRENTLY d_id ON d (id);\n-- +goose Down\nDROP TABLE d;\n")},
+		"m/005_noannot.sql":   {Data: []byte("CREATE TABLE e (id int);\n")},
+		"m/006_notx.sql":      {Data: []byte("-- +goose NO TRANSACTION\n-- +goose Up\nCREATE INDEX CONCURRENTLY a ON t (a);\nCREATE INDEX CONCURRENTLY b ON t (b);\n-- +goose Down\nDROP INDEX a;\n")},
+		"m/0_zero.sql":        {},
+		"m/notamigration.sql": {},
+		"m/README.md":         {},
+	}
+
+	problems, err := ValidateMigrationsFS(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ValidationProblem{
+		{"m/002_nodown.sql", 4, ""},
+		{"m/003_semi.sql", 3, ""},
+		{"m/004_index.sql", 4, ""},
+		{"m/005_noannot.sql", 0, ""},
+		{"m/0_zero.sql", 0, ""},
+		{"m/002_nodown.sql", 0, "version 2 is also used by m/002_dup.sql"},
+		{"m/notamigration.sql", 0, ""},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("got %d problems, want %d: %v", len(problems), len(want), problems)
+	}
+	for _, w := range want {
+		found := false
+		for _, p := range problems {
+			if p.Source == w.Source && p.Line == w.Line && (w.Message == "" || p.Message == w.Message) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("missing problem %+v in %v", w, problems)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package goosedb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/kevinburke/goose/lib/goose"
+)
+
+// defaultVersionTable is the name of the version table if
+// DBConf.VersionTable is empty.
+const defaultVersionTable = "goose_db_version"
+
+// versionTableName returns the unquoted name of the version table, qualified
+// with its schema if one is configured, for messages and lock names.
+func (c *DBConf) versionTableName() string {
+	name := c.versionTableBase()
+	if schema := c.versionSchema(); schema != "" {
+		return schema + "." + name
+	}
+	return name
+}
+
+// versionTableBase returns the name of the version table without its schema.
+func (c *DBConf) versionTableBase() string {
+	if c.VersionTable != "" {
+		return c.VersionTable
+	}
+	return defaultVersionTable
+}
+
+// versionSchema returns the schema that holds the version table:
+// VersionSchema if it is set, or else the first Postgres schema, so that each
+// schema migrated with PgSchema keeps its own history.
+func (c *DBConf) versionSchema() string {
+	if c.VersionSchema != "" {
+		return c.VersionSchema
+	}
+	if schemas := c.pgSchemas(); len(schemas) > 0 {
+		return schemas[0]
+	}
+	return ""
+}
+
+// versionTable returns the quoted name of the version table, qualified with
+// its schema if one is configured, for use in SQL.
+func (c *DBConf) versionTable() string {
+	return c.qualifiedTable("")
+}
+
+// progressTable returns the quoted name of the table that records the
+// progress of NO TRANSACTION migrations. It is named after the version table
+// so that separate migration histories have separate progress.
+func (c *DBConf) progressTable() string {
+	return c.qualifiedTable("_progress")
+}
+
+// lockTable returns the quoted name of the table a dialect without session
+// locks keeps the migration lock in, which also names the lock for other
+// dialects.
+func (c *DBConf) lockTable() string {
+	return c.qualifiedTable("_lock")
+}
+
+func (c *DBConf) qualifiedTable(suffix string) string {
+	d := c.Driver.Dialect
+	table := quoteIdentifier(d, c.versionTableBase()+suffix)
+	if schema := c.versionSchema(); schema != "" {
+		return quoteIdentifier(d, schema) + "." + table
+	}
+	return table
+}
+
+// versionColumn is a column added to the version table after its original
+// definition of id, version_id, is_applied and tstamp.
+type versionColumn struct {
+	name       string
+	definition string // type and constraints, valid in every dialect
+}
+
+// versionColumns lists the columns added to the version table since it was
+// first defined, oldest first. New tables are created with all of them, and
+// tables created by older versions of goose are upgraded in place by adding
+// whichever are missing.
+var versionColumns = []versionColumn{
+	{"filename", "varchar(255) NULL"},
+	{"checksum", "varchar(64) NULL"},
+	{"baselined", "boolean NULL"},
+	{"duration_ms", "bigint NULL"},
+	{"applied_by", "varchar(255) NULL"},
+	{"hostname", "varchar(255) NULL"},
+	{"goose_version", "varchar(64) NULL"},
+	{"tstamp_utc", "timestamp NULL"},
+}
+
+// insertVersionSql returns the dialect's InsertVersionSql for every column
+// versionRecord.args gives a value for.
+func (c *DBConf) insertVersionSql() string {
+	columns := []string{"version_id", "is_applied"}
+	for _, vc := range versionColumns {
+		columns = append(columns, vc.name)
+	}
+	return c.Driver.Dialect.InsertVersionSql(c.versionTable(), columns)
+}
+
+// versionRecord is a row to insert into the version table.
+type versionRecord struct {
+	version   int64
+	applied   bool
+	filename  string // "" records NULL
+	checksum  string // "" records NULL
+	baselined bool
+
+	// start is when the migration started running, for its duration, or
+	// the zero Time if nothing ran.
+	start time.Time
+}
+
+// args returns the arguments of insertVersionSql for r, adding
+// who recorded it, on which host, with which version of goose, and when.
+func (r versionRecord) args(conf *DBConf) []any {
+	var duration any
+	if !r.start.IsZero() {
+		duration = time.Since(r.start).Milliseconds()
+	}
+	return []any{
+		r.version, r.applied, nullString(r.filename), nullString(r.checksum), r.baselined,
+		duration, nullString(conf.appliedBy()), nullString(hostname()), goose.Version, time.Now().UTC(),
+	}
+}
+
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// appliedBy returns the user to record as applying migrations:
+// conf.AppliedBy, or else the OS user running goose.
+func (c *DBConf) appliedBy() string {
+	if c.AppliedBy != "" {
+		return c.AppliedBy
+	}
+	return osUser()
+}
+
+var osUser = sync.OnceValue(func() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+})
+
+var hostname = sync.OnceValue(func() string {
+	name, _ := os.Hostname()
+	return name
+})
+
+func addVersionColumnSql(table string, c versionColumn) string {
+	return "ALTER TABLE " + table + " ADD COLUMN " + c.name + " " + c.definition
+}
+
+// upgradeVersionTable adds any missing versionColumns to an existing version
+// table. Each column is checked before it is added, since the ALTERs are not
+// run in a transaction: an upgrade that fails partway is finished by the next
+// run.
+func upgradeVersionTable(ctx context.Context, conf *DBConf, db *sql.DB) error {
+	table := conf.versionTable()
+	names := make([]string, len(versionColumns))
+	for i, c := range versionColumns {
+		names[i] = c.name
+	}
+	// the common case: the table is already up to date
+	if ok, err := hasColumns(ctx, db, table, names...); ok || err != nil {
+		return err
+	}
+
+	for _, c := range versionColumns {
+		ok, err := hasColumns(ctx, db, table, c.name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, addVersionColumnSql(table, c)); err != nil {
+			return fmt.Errorf("goosedb: adding column %s to %s: %w", c.name, conf.versionTableName(), err)
+		}
+	}
+	return nil
+}
+
+// hasColumns reports whether table has all of the given columns. Errors other
+// than a missing column, such as a lost connection, are returned.
+func hasColumns(ctx context.Context, db *sql.DB, table string, columns ...string) (bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT "+strings.Join(columns, ", ")+" FROM "+table+" WHERE 1 = 0")
+	if err == nil {
+		rows.Close()
+		return true, nil
+	}
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+	if missingColumn(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("goosedb: checking the columns of %s: %w", table, err)
+}
+
+// missingColumn reports whether err says a query named a column that does
+// not exist. Drivers goose doesn't ship are recognized by their message.
+func missingColumn(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "42703" // undefined_column
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1054 // ER_BAD_FIELD_ERROR
+	}
+	msg := strings.ToLower(err.Error())
+	if !strings.Contains(msg, "column") {
+		return false
+	}
+	for _, s := range []string{"no such", "unknown", "does not exist", "missing", "invalid", "not found"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package goosedb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kevinburke/goose/lib/goose"
+)
+
+func TestVersionTableAudit(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+		"002_two.sql": tableMigration("two"),
+	})
+	conf.AppliedBy = "deploy-bot"
+
+	before := time.Now().UTC().Add(-time.Second)
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 2, db); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := GetMigrationStatus(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("got %d statuses", len(statuses))
+	}
+	for _, st := range statuses {
+		if st.AppliedBy != "deploy-bot" || st.GooseVersion != goose.Version || st.Hostname == "" {
+			t.Errorf("version %d: applied by %q on %q with goose %q", st.Version, st.AppliedBy, st.Hostname, st.GooseVersion)
+		}
+		if st.AppliedAt.Location() != time.UTC || st.AppliedAt.Before(before) {
+			t.Errorf("version %d: applied at %v", st.Version, st.AppliedAt)
+		}
+		if st.Duration < 0 {
+			t.Errorf("version %d: duration %v", st.Version, st.Duration)
+		}
+	}
+
+	// duration_ms is recorded even when the migration took under a millisecond
+	var durationMS int64
+	if err := db.QueryRow("SELECT duration_ms FROM goose_db_version WHERE version_id = 2").Scan(&durationMS); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersionTableAuditUpgrade(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+	})
+
+	// a version table created before the audit columns existed
+	if _, err := db.Exec(`CREATE TABLE goose_db_version (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		version_id INTEGER NOT NULL,
+		is_applied INTEGER NOT NULL,
+		tstamp TIMESTAMP DEFAULT (datetime('now'))
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO goose_db_version (version_id, is_applied) VALUES (0, 1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+	statuses, err := GetMigrationStatus(conf, conf.MigrationsDir, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].GooseVersion != goose.Version || statuses[0].AppliedBy == "" {
+		t.Errorf("statuses after upgrade: %+v", statuses)
+	}
+}
+
+func TestHasColumns(t *testing.T) {
+	_, db := newSqliteTest(t, nil)
+	if _, err := db.Exec("CREATE TABLE t (a INTEGER, b INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if ok, err := hasColumns(ctx, db, "t", "a", "b"); !ok || err != nil {
+		t.Errorf("existing columns: got %v, %v", ok, err)
+	}
+	if ok, err := hasColumns(ctx, db, "t", "a", "c"); ok || err != nil {
+		t.Errorf("missing column: got %v, %v, want false, nil", ok, err)
+	}
+
+	// only a missing column means false; other errors are returned
+	if _, err := hasColumns(ctx, db, "missing", "a"); err == nil {
+		t.Error("missing table: want an error")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := hasColumns(canceled, db, "t", "c"); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled context: got %v, want context.Canceled", err)
+	}
+}
+
+func TestVersionTableResumeCreate(t *testing.T) {
+	conf, db := newSqliteTest(t, map[string]string{
+		"001_one.sql": tableMigration("one"),
+	})
+
+	// a run that failed after adding some of the newer columns, before
+	// inserting version 0
+	if _, err := db.Exec(Sqlite3Dialect{}.CreateVersionTableSql("goose_db_version")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(addVersionColumnSql("goose_db_version", versionColumns[0])); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 0 {
+		t.Fatalf("EnsureDBVersion: got %d, %v, want 0", v, err)
+	}
+	if err := RunMigrationsOnDb(conf, conf.MigrationsDir, 1, db); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := EnsureDBVersion(conf, db); err != nil || v != 1 {
+		t.Errorf("after migrating: got %d, %v, want 1", v, err)
+	}
+}
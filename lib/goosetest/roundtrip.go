@@ -0,0 +1,184 @@
+// Package goosetest checks that migrations can be rolled back: that the Down
+// section of each migration undoes its Up section.
+//
+// In a test, RoundTripFS checks the migrations in an fs.FS against an
+// in-memory sqlite3 database:
+//
+//	func TestMigrations(t *testing.T) {
+//		goosetest.RoundTripFS(t, os.DirFS("db/migrations"), ".")
+//	}
+//
+// RoundTrip checks them against any database, such as a Postgres server
+// started for the test.
+package goosetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/kevinburke/goose/lib/goose"
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+// RoundTripError reports the first migration that could not be rolled back
+// faithfully: either running it failed, or its schema after running up and
+// then down differs from the schema before it ran up.
+type RoundTripError struct {
+	Version int64
+	Source  string // path to the migration file, or name of a Go migration
+
+	// Step is what failed: running the migration "up", "down" or "re-apply"
+	// (up again), or, if it ran but left a different schema, "compare down"
+	// or "compare re-apply".
+	Step string
+
+	// Before and After are the schemas compared: before the migration ran
+	// up and after it ran down, or after it first ran up and after it was
+	// re-applied. They are nil unless Step is a comparison.
+	Before, After Snapshot
+
+	Err error // the error running the migration, if one failed
+}
+
+func (e *RoundTripError) Error() string {
+	name := filepath.Base(e.Source)
+	if e.Err != nil {
+		return fmt.Sprintf("goosetest: %s (version %d): %s failed: %v", name, e.Version, e.Step, e.Err)
+	}
+	what := "schema after down differs from before up"
+	if e.Step == "compare re-apply" {
+		what = "schema after re-applying differs from after the first up"
+	}
+	return fmt.Sprintf("goosetest: %s (version %d): %s:%s", name, e.Version, what, diff(e.Before, e.After))
+}
+
+func (e *RoundTripError) Unwrap() error {
+	return e.Err
+}
+
+// CheckRoundTrip checks each pending migration for conf, in order: it
+// snapshots the schema, runs the migration up, runs it down, checks that the
+// schema matches the snapshot, and runs it up again. It returns a
+// *RoundTripError for the first migration that doesn't round-trip, leaving
+// db at the version before that migration, or nil after applying every
+// migration.
+func CheckRoundTrip(conf *goosedb.DBConf, db *sql.DB) error {
+	return CheckRoundTripContext(context.Background(), conf, db)
+}
+
+// CheckRoundTripContext is like CheckRoundTrip but uses ctx for every query.
+func CheckRoundTripContext(ctx context.Context, conf *goosedb.DBConf, db *sql.DB) error {
+	current, err := goosedb.EnsureDBVersionContext(ctx, conf, db)
+	if err != nil {
+		return err
+	}
+	var migrations []*goose.Migration
+	if conf.MigrationsFS != nil {
+		migrations, err = goose.CollectTrackMigrationsFS(conf.Track, conf.MigrationsFS, conf.MigrationsDir, current, math.MaxInt64)
+	} else {
+		migrations, err = goose.CollectTrackMigrations(conf.Track, conf.MigrationsDir, current, math.MaxInt64)
+	}
+	if err != nil {
+		return err
+	}
+
+	run := func(m *goose.Migration, step string, target int64) error {
+		if err := goosedb.RunMigrationsOnDbContext(ctx, conf, conf.MigrationsDir, target, db); err != nil {
+			return &RoundTripError{Version: m.Version, Source: m.Source, Step: step, Err: err}
+		}
+		return nil
+	}
+	compare := func(m *goose.Migration, step string, before Snapshot) error {
+		after, err := TakeSnapshot(ctx, conf, db)
+		if err != nil {
+			return err
+		}
+		if diff(before, after) != "" {
+			return &RoundTripError{Version: m.Version, Source: m.Source, Step: step, Before: before, After: after}
+		}
+		return nil
+	}
+
+	previous := current
+	for _, m := range migrations {
+		before, err := TakeSnapshot(ctx, conf, db)
+		if err != nil {
+			return err
+		}
+		if err := run(m, "up", m.Version); err != nil {
+			return err
+		}
+		up, err := TakeSnapshot(ctx, conf, db)
+		if err != nil {
+			return err
+		}
+		if err := run(m, "down", previous); err != nil {
+			return err
+		}
+		if err := compare(m, "compare down", before); err != nil {
+			return err
+		}
+		if err := run(m, "re-apply", m.Version); err != nil {
+			return err
+		}
+		if err := compare(m, "compare re-apply", up); err != nil {
+			return err
+		}
+		previous = m.Version
+	}
+	return nil
+}
+
+// RoundTrip runs CheckRoundTrip and fails t if any migration doesn't
+// round-trip. If conf.Logger is nil, goose's output goes to t.Log.
+func RoundTrip(t testing.TB, conf *goosedb.DBConf, db *sql.DB) {
+	t.Helper()
+	if conf.Logger == nil {
+		c := *conf
+		c.Logger = slog.New(testHandler{t})
+		conf = &c
+	}
+	if err := CheckRoundTripContext(context.Background(), conf, db); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// RoundTripFS runs RoundTrip for the migrations in dir in fsys, along with
+// any registered Go migrations, against a new in-memory sqlite3 database.
+func RoundTripFS(t testing.TB, fsys fs.FS, dir string) {
+	t.Helper()
+	conf, err := goosedb.NewConfig("sqlite3", ":memory:", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.MigrationsFS = fsys
+	db, err := goosedb.OpenDBFromDBConf(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// every connection to :memory: opens a database of its own
+	db.SetMaxOpenConns(1)
+	RoundTrip(t, conf, db)
+}
+
+// testHandler logs the message of each record at Info or above with t.Log.
+type testHandler struct{ t testing.TB }
+
+func (h testHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelInfo
+}
+
+func (h testHandler) Handle(_ context.Context, r slog.Record) error {
+	h.t.Log(r.Message)
+	return nil
+}
+
+func (h testHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h testHandler) WithGroup(string) slog.Handler      { return h }
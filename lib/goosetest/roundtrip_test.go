@@ -0,0 +1,157 @@
+package goosetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/kevinburke/goose/lib/goose"
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+var goodMigrations = fstest.MapFS{
+	"001_users.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE users (id int PRIMARY KEY, email text);
+CREATE INDEX users_email ON users (email);
+
+-- +goose Down
+DROP TABLE users;
+`)},
+	"002_name.sql": {Data: []byte(`-- +goose Up
+ALTER TABLE users ADD COLUMN name text;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN name;
+`)},
+	"003_posts.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE posts (id int, user_id int REFERENCES users (id));
+CREATE VIEW post_authors AS SELECT posts.id, users.email FROM posts JOIN users ON users.id = posts.user_id;
+
+-- +goose Down
+DROP VIEW post_authors;
+DROP TABLE posts;
+`)},
+}
+
+func TestRoundTripFS(t *testing.T) {
+	RoundTripFS(t, goodMigrations, ".")
+}
+
+func newMemoryTest(t *testing.T, fsys fstest.MapFS) (*goosedb.DBConf, *sql.DB) {
+	t.Helper()
+	conf, err := goosedb.NewConfig("sqlite3", ":memory:", ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.MigrationsFS = fsys
+	conf.Logger = slog.New(slog.DiscardHandler)
+	db, err := goosedb.OpenDBFromDBConf(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return conf, db
+}
+
+func TestCheckRoundTripIncompleteDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_users.sql": goodMigrations["001_users.sql"],
+		// the down leaves the index behind
+		"002_email_index.sql": {Data: []byte(`-- +goose Up
+CREATE UNIQUE INDEX users_email_unique ON users (email);
+
+-- +goose Down
+SELECT 1;
+`)},
+		"003_never_reached.sql": goodMigrations["003_posts.sql"],
+	}
+	conf, db := newMemoryTest(t, fsys)
+
+	err := CheckRoundTrip(conf, db)
+	var rtErr *RoundTripError
+	if !errors.As(err, &rtErr) {
+		t.Fatalf("expected a RoundTripError, got %v", err)
+	}
+	if rtErr.Version != 2 || rtErr.Step != "compare down" {
+		t.Errorf("got version %d step %q", rtErr.Version, rtErr.Step)
+	}
+	if !strings.Contains(err.Error(), "+ index users_email_unique") {
+		t.Errorf("error doesn't describe the leftover index: %v", err)
+	}
+	if v, err := goosedb.EnsureDBVersion(conf, db); err != nil || v != 1 {
+		t.Errorf("version after the failure: got %d, %v, want 1", v, err)
+	}
+}
+
+func TestCheckRoundTripFailingDown(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_users.sql": {Data: []byte(`-- +goose Up
+CREATE TABLE users (id int);
+
+-- +goose Down
+DROP TABLE no_such_table;
+`)},
+	}
+	conf, db := newMemoryTest(t, fsys)
+
+	err := CheckRoundTrip(conf, db)
+	var rtErr *RoundTripError
+	if !errors.As(err, &rtErr) || rtErr.Step != "down" || rtErr.Version != 1 {
+		t.Fatalf("expected down of version 1 to fail, got %v", err)
+	}
+	var migrationErr *goosedb.MigrationError
+	if !errors.As(err, &migrationErr) {
+		t.Errorf("expected the MigrationError to be wrapped, got %v", err)
+	}
+}
+
+func TestCheckRoundTripGoMigration(t *testing.T) {
+	t.Cleanup(goose.ResetMigrations)
+
+	// a Go migration whose down does nothing leaves its table behind
+	goose.RegisterMigration(2, "seed", func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "CREATE TABLE seeded (id int)")
+		return err
+	}, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+	conf, db := newMemoryTest(t, fstest.MapFS{"001_users.sql": goodMigrations["001_users.sql"]})
+
+	err := CheckRoundTrip(conf, db)
+	var rtErr *RoundTripError
+	if !errors.As(err, &rtErr) || rtErr.Version != 2 {
+		t.Fatalf("expected version 2 to fail, got %v", err)
+	}
+	if rtErr.Step != "compare down" {
+		t.Errorf("got step %q, want compare down", rtErr.Step)
+	}
+}
+
+func TestRegisterSnapshot(t *testing.T) {
+	conf, db := newMemoryTest(t, goodMigrations)
+	conf.Driver.Name = "custom"
+
+	called := 0
+	RegisterSnapshot("custom", func(ctx context.Context, conf *goosedb.DBConf, db *sql.DB) (Snapshot, error) {
+		called++
+		return Snapshot{}, nil
+	})
+	t.Cleanup(func() {
+		snapshotsMu.Lock()
+		delete(snapshots, "custom")
+		snapshotsMu.Unlock()
+	})
+
+	if err := CheckRoundTrip(conf, db); err != nil {
+		t.Fatal(err)
+	}
+	// before, after up, after down and after re-applying each migration
+	if called != 4*len(goodMigrations) {
+		t.Errorf("snapshot called %d times", called)
+	}
+}
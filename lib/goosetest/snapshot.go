@@ -0,0 +1,206 @@
+package goosetest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kevinburke/goose/lib/goosedb"
+)
+
+// Snapshot describes a database schema: the definition of each table,
+// column, index, view and so on, keyed by the kind and name of the object,
+// such as "column users.email". Two snapshots of the same schema are equal.
+type Snapshot map[string]string
+
+// SnapshotFunc returns a Snapshot of the schema of db that conf migrates.
+type SnapshotFunc func(ctx context.Context, conf *goosedb.DBConf, db *sql.DB) (Snapshot, error)
+
+var (
+	snapshotsMu sync.RWMutex
+	snapshots   = map[string]SnapshotFunc{
+		"postgres": snapshotPostgres,
+		"mysql":    snapshotMySQL,
+		"sqlite3":  snapshotSqlite3,
+	}
+)
+
+// RegisterSnapshot makes f the way to snapshot the schema of databases whose
+// driver or dialect is registered under name, as with
+// goosedb.RegisterDialect. Registering a name again replaces the earlier
+// function, including the built-in postgres, mysql and sqlite3 ones.
+func RegisterSnapshot(name string, f SnapshotFunc) {
+	snapshotsMu.Lock()
+	defer snapshotsMu.Unlock()
+	snapshots[name] = f
+}
+
+// snapshotFunc returns the SnapshotFunc for conf's driver, or for its
+// dialect if it is one of the built-in ones.
+func snapshotFunc(conf *goosedb.DBConf) (SnapshotFunc, error) {
+	name := conf.Driver.Name
+	switch conf.Driver.Dialect.(type) {
+	case goosedb.PostgresDialect, *goosedb.PostgresDialect:
+		name = "postgres"
+	case goosedb.MySqlDialect, *goosedb.MySqlDialect:
+		name = "mysql"
+	case goosedb.Sqlite3Dialect, *goosedb.Sqlite3Dialect:
+		name = "sqlite3"
+	}
+	snapshotsMu.RLock()
+	defer snapshotsMu.RUnlock()
+	if f, ok := snapshots[conf.Driver.Name]; ok {
+		return f, nil
+	}
+	if f, ok := snapshots[name]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("goosetest: no snapshot for driver %q; register one with RegisterSnapshot", conf.Driver.Name)
+}
+
+// TakeSnapshot returns a Snapshot of the schema of db, leaving out goose's
+// own tables.
+func TakeSnapshot(ctx context.Context, conf *goosedb.DBConf, db *sql.DB) (Snapshot, error) {
+	f, err := snapshotFunc(conf)
+	if err != nil {
+		return nil, err
+	}
+	return f(ctx, conf, db)
+}
+
+// diff describes how after differs from before, one line per object, or
+// returns "" if they are equal.
+func diff(before, after Snapshot) string {
+	keys := make(map[string]bool, len(before)+len(after))
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var b strings.Builder
+	for _, k := range sorted {
+		was, inBefore := before[k]
+		is, inAfter := after[k]
+		switch {
+		case !inAfter:
+			fmt.Fprintf(&b, "\n\t- %s: %s", k, was)
+		case !inBefore:
+			fmt.Fprintf(&b, "\n\t+ %s: %s", k, is)
+		case was != is:
+			fmt.Fprintf(&b, "\n\t- %s: %s\n\t+ %s: %s", k, was, k, is)
+		}
+	}
+	return b.String()
+}
+
+// gooseTable reports whether table is one goose keeps its own state in: the
+// version table or its progress or sqlite3 lock table.
+func gooseTable(conf *goosedb.DBConf, table string) bool {
+	version := conf.VersionTable
+	if version == "" {
+		version = "goose_db_version"
+	}
+	switch strings.ToLower(table) {
+	case strings.ToLower(version), strings.ToLower(version) + "_progress", strings.ToLower(version) + "_lock":
+		return true
+	}
+	return false
+}
+
+// query adds to s the rows of q, each a table name, the key of an object in
+// it and the object's definition, skipping goose's own tables.
+func (s Snapshot) query(ctx context.Context, conf *goosedb.DBConf, db *sql.DB, q string) error {
+	rows, err := db.QueryContext(ctx, q)
+	if err != nil {
+		return fmt.Errorf("goosetest: snapshot: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, key string
+		var def sql.NullString
+		if err := rows.Scan(&table, &key, &def); err != nil {
+			return fmt.Errorf("goosetest: snapshot: %w", err)
+		}
+		if gooseTable(conf, table) {
+			continue
+		}
+		s[key] = strings.Join(strings.Fields(def.String), " ")
+	}
+	return rows.Err()
+}
+
+func snapshotSqlite3(ctx context.Context, conf *goosedb.DBConf, db *sql.DB) (Snapshot, error) {
+	s := Snapshot{}
+	err := s.query(ctx, conf, db, `SELECT tbl_name, type || ' ' || name, sql FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%'`)
+	return s, err
+}
+
+// pgSchemas limits the Postgres snapshot to the schemas on the search path.
+const pgSchemas = "ANY (current_schemas(false))"
+
+var postgresSnapshotQueries = []string{
+	`SELECT table_name, 'table ' || table_schema || '.' || table_name, table_type
+		FROM information_schema.tables WHERE table_schema::name = ` + pgSchemas,
+	`SELECT table_name, 'column ' || table_schema || '.' || table_name || '.' || column_name,
+		concat_ws(' ', data_type, character_maximum_length, is_nullable, column_default)
+		FROM information_schema.columns WHERE table_schema::name = ` + pgSchemas,
+	`SELECT tablename, 'index ' || schemaname || '.' || indexname, indexdef
+		FROM pg_indexes WHERE schemaname = ` + pgSchemas,
+	`SELECT cl.relname, 'constraint ' || n.nspname || '.' || cl.relname || '.' || c.conname, pg_get_constraintdef(c.oid)
+		FROM pg_constraint c JOIN pg_namespace n ON n.oid = c.connamespace JOIN pg_class cl ON cl.oid = c.conrelid
+		WHERE n.nspname = ` + pgSchemas,
+	`SELECT viewname, 'view ' || schemaname || '.' || viewname, definition
+		FROM pg_views WHERE schemaname = ` + pgSchemas,
+	`SELECT sequence_name, 'sequence ' || sequence_schema || '.' || sequence_name, data_type
+		FROM information_schema.sequences WHERE sequence_schema::name = ` + pgSchemas,
+	`SELECT t.typname, 'type ' || n.nspname || '.' || t.typname, string_agg(e.enumlabel, ', ' ORDER BY e.enumsortorder)
+		FROM pg_type t JOIN pg_namespace n ON n.oid = t.typnamespace JOIN pg_enum e ON e.enumtypid = t.oid
+		WHERE n.nspname = ` + pgSchemas + ` GROUP BY n.nspname, t.typname`,
+}
+
+func snapshotPostgres(ctx context.Context, conf *goosedb.DBConf, db *sql.DB) (Snapshot, error) {
+	s := Snapshot{}
+	for _, q := range postgresSnapshotQueries {
+		if err := s.query(ctx, conf, db, q); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+var mysqlSnapshotQueries = []string{
+	`SELECT table_name, CONCAT('table ', table_name), table_type
+		FROM information_schema.tables WHERE table_schema = DATABASE()`,
+	`SELECT table_name, CONCAT('column ', table_name, '.', column_name),
+		CONCAT_WS(' ', column_type, is_nullable, column_default, extra)
+		FROM information_schema.columns WHERE table_schema = DATABASE()`,
+	`SELECT table_name, CONCAT('index ', table_name, '.', index_name),
+		CONCAT(IF(non_unique, 'non-unique ', 'unique '), GROUP_CONCAT(column_name ORDER BY seq_in_index))
+		FROM information_schema.statistics WHERE table_schema = DATABASE()
+		GROUP BY table_name, index_name, non_unique`,
+	`SELECT table_name, CONCAT('constraint ', table_name, '.', constraint_name), constraint_type
+		FROM information_schema.table_constraints WHERE table_schema = DATABASE()`,
+	`SELECT table_name, CONCAT('view ', table_name), view_definition
+		FROM information_schema.views WHERE table_schema = DATABASE()`,
+}
+
+func snapshotMySQL(ctx context.Context, conf *goosedb.DBConf, db *sql.DB) (Snapshot, error) {
+	s := Snapshot{}
+	for _, q := range mysqlSnapshotQueries {
+		if err := s.query(ctx, conf, db, q); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}